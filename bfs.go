@@ -0,0 +1,220 @@
+package rush
+
+import "math"
+
+/*
+
+BFSSolver explores the states of a board breadth first. Unlike Solver, which
+stops at the first optimal path it finds, it keeps every state within the
+optimal distance, grouped into layers by their distance from the start. A
+second, backward pass over the layers counts how many shortest paths lead
+from each state to a solved state, so that every optimal solution can be
+counted and enumerated without searching again.
+
+Two different moves never lead to the same state, so distinct paths are
+distinct move sequences.
+
+BFS needs memory for every state it visits, so it is best suited to boards
+of ordinary size.
+
+*/
+
+type BFSSolver struct {
+	board    *Board
+	goal     Goal
+	searched bool
+	layers   [][]*Board
+	dist     map[MemoKey]int
+	counts   map[MemoKey]int
+	hits     uint64
+}
+
+func NewBFSSolver(board *Board) *BFSSolver {
+	return NewBFSSolverWithGoal(board, DefaultGoal(board))
+}
+
+// NewBFSSolverWithGoal returns a BFSSolver that searches for states
+// satisfying goal.
+func NewBFSSolverWithGoal(board *Board, goal Goal) *BFSSolver {
+	return &BFSSolver{board: board.Copy(), goal: goal}
+}
+
+func (s *BFSSolver) search() {
+	if s.searched {
+		return
+	}
+	s.searched = true
+	board := s.board
+	if err := board.Validate(); err != nil {
+		return
+	}
+	if isDefaultGoal(board, s.goal) && board.Impossible() {
+		return
+	}
+
+	// forward pass: find the distance of every state up to the first layer
+	// that contains a solved state
+	s.dist = make(map[MemoKey]int)
+	s.dist[*board.MemoKey()] = 0
+	layer := []*Board{board.Copy()}
+	found := s.goal.IsSolved(board)
+	s.layers = append(s.layers, layer)
+	var buf []Move
+	for d := 1; !found && len(layer) > 0; d++ {
+		var next []*Board
+		for _, b := range layer {
+			buf = b.Moves(buf)
+			for _, move := range buf {
+				b.DoMove(move)
+				s.hits++
+				key := *b.MemoKey()
+				if _, ok := s.dist[key]; !ok {
+					s.dist[key] = d
+					next = append(next, b.Copy())
+					if s.goal.IsSolved(b) {
+						found = true
+					}
+				}
+				b.UndoMove(move)
+			}
+		}
+		layer = next
+		s.layers = append(s.layers, layer)
+	}
+	if !found {
+		s.layers = nil
+		return
+	}
+
+	// backward pass: count the shortest paths from each state
+	depth := len(s.layers) - 1
+	s.counts = make(map[MemoKey]int)
+	for _, b := range s.layers[depth] {
+		if s.goal.IsSolved(b) {
+			s.counts[*b.MemoKey()] = 1
+		}
+	}
+	for d := depth - 1; d >= 0; d-- {
+		for _, b := range s.layers[d] {
+			count := 0
+			buf = b.Moves(buf)
+			for _, move := range buf {
+				b.DoMove(move)
+				if key := b.MemoKey(); s.dist[*key] == d+1 {
+					count = addCounts(count, s.counts[*key])
+				}
+				b.UndoMove(move)
+			}
+			if count > 0 {
+				s.counts[*b.MemoKey()] = count
+			}
+		}
+	}
+}
+
+// addCounts adds two path counts, saturating at math.MaxInt64.
+func addCounts(a, b int) int {
+	if a > math.MaxInt64-b {
+		return math.MaxInt64
+	}
+	return a + b
+}
+
+func (s *BFSSolver) solvable() bool {
+	s.search()
+	return s.layers != nil
+}
+
+// Layers returns the states reachable from the board grouped by their
+// distance from it, up to the length of an optimal solution. It returns nil
+// if the board is unsolvable.
+func (s *BFSSolver) Layers() [][]*Board {
+	s.search()
+	return s.layers
+}
+
+// Distance returns the number of moves needed to reach board from the
+// starting board, if it is within the layers explored.
+func (s *BFSSolver) Distance(board *Board) (int, bool) {
+	s.search()
+	d, ok := s.dist[*board.MemoKey()]
+	return d, ok
+}
+
+// CountSolutions returns the number of distinct optimal solutions. The
+// count saturates at math.MaxInt64.
+func (s *BFSSolver) CountSolutions() int {
+	if !s.solvable() {
+		return 0
+	}
+	return s.counts[*s.board.MemoKey()]
+}
+
+// Solve returns the first optimal solution in move generation order.
+func (s *BFSSolver) Solve() Solution {
+	solutions := s.Solutions(1)
+	if len(solutions) == 0 {
+		return Solution{MemoSize: len(s.dist), MemoHits: s.hits}
+	}
+	return solutions[0]
+}
+
+// Solutions returns up to limit distinct optimal solutions, or all of them
+// if limit <= 0.
+func (s *BFSSolver) Solutions(limit int) []Solution {
+	if !s.solvable() {
+		return nil
+	}
+	board := s.board
+	depth := len(s.layers) - 1
+	if depth == 0 {
+		return []Solution{s.solution(nil)}
+	}
+	var result []Solution
+	path := make([]Move, depth)
+	moves := make([][]Move, depth)
+	var f func(int) bool
+	f = func(d int) bool {
+		if d == depth {
+			result = append(result, s.solution(path))
+			return limit <= 0 || len(result) < limit
+		}
+		moves[d] = board.Moves(moves[d])
+		for _, move := range moves[d] {
+			board.DoMove(move)
+			key := board.MemoKey()
+			ok := true
+			if s.counts[*key] > 0 && s.dist[*key] == d+1 {
+				path[d] = move
+				ok = f(d + 1)
+			}
+			board.UndoMove(move)
+			if !ok {
+				return false
+			}
+		}
+		return true
+	}
+	f(0)
+	return result
+}
+
+func (s *BFSSolver) solution(path []Move) Solution {
+	moves := make([]Move, len(path))
+	copy(moves, path)
+	steps := 0
+	for _, move := range moves {
+		steps += move.AbsSteps()
+	}
+	return Solution{
+		Solvable: true,
+		Moves:    moves,
+		Labels:   s.board.Labels,
+		NumMoves: len(moves),
+		NumSteps: steps,
+		Cost:     len(moves),
+		Depth:    len(moves),
+		MemoSize: len(s.dist),
+		MemoHits: s.hits,
+	}
+}
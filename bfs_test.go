@@ -0,0 +1,38 @@
+package rush
+
+import "testing"
+
+func TestBFSSolver(t *testing.T) {
+	board, err := NewBoardFromString("BB.C..D..C..DAAC..D.EE..F.....F.GGG.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := board.Solve()
+	s := NewBFSSolver(board)
+	solutions := s.Solutions(0)
+	if len(solutions) != 10 || s.CountSolutions() != 10 {
+		t.Fatalf("got %d solutions and a count of %d, want 10", len(solutions), s.CountSolutions())
+	}
+	seen := make(map[string]bool)
+	for _, solution := range solutions {
+		if solution.NumMoves != expected.NumMoves {
+			t.Fatalf("got %d moves, want %d", solution.NumMoves, expected.NumMoves)
+		}
+		if err := board.CheckSolution(solution.Moves); err != nil {
+			t.Fatal(err)
+		}
+		seen[solution.String()] = true
+	}
+	if len(seen) != len(solutions) {
+		t.Fatal("duplicate solutions")
+	}
+	if d, ok := s.Distance(board); !ok || d != 0 {
+		t.Fatalf("got distance %d, want 0", d)
+	}
+	if len(s.Layers()) != expected.NumMoves+1 {
+		t.Fatalf("got %d layers, want %d", len(s.Layers()), expected.NumMoves+1)
+	}
+	if len(s.Solutions(2)) != 2 {
+		t.Fatal("expected limit to be respected")
+	}
+}
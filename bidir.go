@@ -0,0 +1,369 @@
+package rush
+
+import (
+	"context"
+	"sort"
+)
+
+/*
+
+BidirectionalSolver searches breadth first from the starting board and
+backward from the solved states at the same time, always growing the smaller
+of the two frontiers, until they meet. Every move can be undone, so the
+backward search is an ordinary search from the solved states. For a solution
+of n moves each side only needs to reach about n/2 moves deep, where Solver
+repeats all of its shallower iterations on the way to n.
+
+The solved states are not known in advance, so they are enumerated: every
+arrangement with the targeted pieces at their targets and the other pieces
+anywhere they could be. A piece can never pass a wall or another piece in
+its own lane, which rules out most arrangements that are not reachable. The
+rest are harmless, as the forward search never meets them, but they do cost
+memory, so this works best on boards that are crowded or have few pieces.
+
+Only goals that place pieces at targets can be enumerated. For any other
+goal BidirectionalSolver falls back to Solver.
+
+*/
+
+type BidirectionalSolver struct {
+	board  *Board
+	goal   Goal
+	limits Limits
+	ctx    context.Context
+	nodes  uint64
+	hits   uint64
+}
+
+func NewBidirectionalSolver(board *Board) *BidirectionalSolver {
+	return NewBidirectionalSolverWithGoal(board, DefaultGoal(board))
+}
+
+// NewBidirectionalSolverWithGoal returns a BidirectionalSolver that searches
+// for a state satisfying goal.
+func NewBidirectionalSolverWithGoal(board *Board, goal Goal) *BidirectionalSolver {
+	return &BidirectionalSolver{board: board, goal: goal}
+}
+
+// SetLimits bounds the work done by later solves. Nodes are states expanded
+// by either side and the memo size counts the states seen by both, including
+// every enumerated solved state.
+func (s *BidirectionalSolver) SetLimits(limits Limits) {
+	s.limits = limits
+}
+
+// bidirNode records how a side of the search first reached a state: its
+// distance from that side's start and the last move made to get there.
+type bidirNode struct {
+	depth int
+	move  Move
+}
+
+func (s *BidirectionalSolver) Solve() Solution {
+	board := s.board
+	if err := board.Validate(); err != nil {
+		return Solution{}
+	}
+	targets, ok := goalTargets(s.goal)
+	if !ok {
+		solver := NewSolverWithGoal(board, s.goal)
+		solver.SetLimits(s.limits)
+		if s.ctx != nil {
+			return solver.SolveContext(s.ctx)
+		}
+		return solver.Solve()
+	}
+	if isDefaultGoal(board, s.goal) && board.Impossible() {
+		return Solution{}
+	}
+	if s.goal.IsSolved(board) {
+		return Solution{Solvable: true, Labels: board.Labels}
+	}
+
+	s.nodes = 0
+	s.hits = 0
+	start := board.Copy()
+	forward := map[MemoKey]bidirNode{*start.MemoKey(): {}}
+	backward := make(map[MemoKey]bidirNode)
+	goals, err := s.goalStates(targets)
+	if err != nil {
+		return Solution{Nodes: s.nodes, Aborted: err}
+	}
+	for _, b := range goals {
+		backward[*b.MemoKey()] = bidirNode{}
+	}
+
+	forwardLayer := []*Board{start}
+	backwardLayer := goals
+	for len(forwardLayer) > 0 && len(backwardLayer) > 0 {
+		var meet *Board
+		if len(forwardLayer) <= len(backwardLayer) {
+			forwardLayer, meet, err = s.expand(forwardLayer, forward, backward)
+		} else {
+			backwardLayer, meet, err = s.expand(backwardLayer, backward, forward)
+		}
+		if err != nil {
+			return Solution{
+				MemoSize: len(forward) + len(backward),
+				MemoHits: s.hits,
+				Nodes:    s.nodes,
+				Aborted:  err,
+			}
+		}
+		if meet != nil {
+			return s.solution(meet, forward, backward)
+		}
+	}
+	return Solution{MemoSize: len(forward) + len(backward), MemoHits: s.hits, Nodes: s.nodes}
+}
+
+// SolveContext is like Solve but stops early, returning a Solution with
+// Aborted set, if ctx is done.
+func (s *BidirectionalSolver) SolveContext(ctx context.Context) Solution {
+	s.ctx = ctx
+	defer func() { s.ctx = nil }()
+	return s.Solve()
+}
+
+// expand searches one layer deeper on one side, recording new states in
+// seen. It returns the next layer, or if the sides met, the meeting state
+// on the shortest path between them.
+func (s *BidirectionalSolver) expand(layer []*Board, seen, other map[MemoKey]bidirNode) ([]*Board, *Board, error) {
+	var next []*Board
+	var meet *Board
+	best := 0
+	var buf []Move
+	for _, b := range layer {
+		s.nodes++
+		if err := s.abort(len(seen) + len(other)); err != nil {
+			return nil, nil, err
+		}
+		depth := seen[*b.MemoKey()].depth + 1
+		buf = b.Moves(buf)
+		for _, move := range buf {
+			b.DoMove(move)
+			s.hits++
+			key := *b.MemoKey()
+			if _, ok := seen[key]; !ok {
+				seen[key] = bidirNode{depth, move}
+				if n, ok := other[key]; ok {
+					// finish the layer, since a later meeting may be shorter
+					if meet == nil || n.depth < best {
+						meet = b.Copy()
+						best = n.depth
+					}
+				} else {
+					next = append(next, b.Copy())
+				}
+			}
+			b.UndoMove(move)
+		}
+	}
+	return next, meet, nil
+}
+
+func (s *BidirectionalSolver) abort(memoSize int) error {
+	limits := s.limits
+	if limits.MaxNodes > 0 && s.nodes > limits.MaxNodes {
+		return ErrNodeLimit
+	}
+	if limits.MaxMemoSize > 0 && memoSize > limits.MaxMemoSize {
+		return ErrMemoLimit
+	}
+	if s.ctx != nil && s.nodes%contextCheckInterval == 0 {
+		return s.ctx.Err()
+	}
+	return nil
+}
+
+// solution joins the path from the start to meet with the path from meet to
+// a solved state.
+func (s *BidirectionalSolver) solution(meet *Board, forward, backward map[MemoKey]bidirNode) Solution {
+	var moves []Move
+	b := meet.Copy()
+	for n := forward[*b.MemoKey()]; n.depth > 0; n = forward[*b.MemoKey()] {
+		moves = append(moves, n.move)
+		b.UndoMove(n.move)
+	}
+	for i, j := 0, len(moves)-1; i < j; i, j = i+1, j-1 {
+		moves[i], moves[j] = moves[j], moves[i]
+	}
+	b = meet.Copy()
+	for n := backward[*b.MemoKey()]; n.depth > 0; n = backward[*b.MemoKey()] {
+		move := Move{n.move.Piece, -n.move.Steps}
+		moves = append(moves, move)
+		b.DoMove(move)
+	}
+	steps := 0
+	for _, move := range moves {
+		steps += move.AbsSteps()
+	}
+	return Solution{
+		Solvable: true,
+		Moves:    moves,
+		Labels:   s.board.Labels,
+		NumMoves: len(moves),
+		NumSteps: steps,
+		Cost:     len(moves),
+		Depth:    len(moves),
+		MemoSize: len(forward) + len(backward),
+		MemoHits: s.hits,
+		Nodes:    s.nodes,
+	}
+}
+
+// goalTargets returns the target position of each piece that goal places,
+// if goal is made only of piece targets.
+func goalTargets(goal Goal) (map[int]int, bool) {
+	switch g := goal.(type) {
+	case PieceTarget:
+		return map[int]int{g.Piece: g.Position}, true
+	case PieceTargets:
+		targets := make(map[int]int)
+		for _, t := range g {
+			if p, ok := targets[t.Piece]; ok && p != t.Position {
+				// no state satisfies both, which Solver will find
+				return nil, false
+			}
+			targets[t.Piece] = t.Position
+		}
+		return targets, true
+	}
+	return nil, false
+}
+
+// laneRange describes where a piece may be placed in its lane, by the
+// offset of its first cell.
+type laneRange struct {
+	piece       int
+	orientation Orientation
+	lane        int
+	offset      int
+	lo, hi      int
+}
+
+// goalStates enumerates the arrangements of the board's pieces with each
+// targeted piece at its target, every other piece between the walls that
+// bound it now, and the pieces sharing a lane in their current order.
+func (s *BidirectionalSolver) goalStates(targets map[int]int) ([]*Board, error) {
+	board := s.board
+	w, h := board.Width, board.Height
+	occupied := make([]bool, w*h)
+	for _, i := range board.Walls {
+		occupied[i] = true
+	}
+
+	ranges := make([]laneRange, len(board.Pieces))
+	for i, piece := range board.Pieces {
+		r := laneRange{piece: i, orientation: piece.Orientation}
+		r.lane, r.offset = piece.Row(w), piece.Col(w)
+		last := w - piece.Size
+		if piece.Orientation == Vertical {
+			r.lane, r.offset = piece.Col(w), piece.Row(w)
+			last = h - piece.Size
+		}
+		// slide both ways until a wall or the edge
+		stride := piece.Stride(w)
+		r.lo, r.hi = r.offset, r.offset
+		for r.lo > 0 && !occupied[piece.Position+(r.lo-1-r.offset)*stride] {
+			r.lo--
+		}
+		for r.hi < last && !occupied[piece.Position+(r.hi+piece.Size-r.offset)*stride] {
+			r.hi++
+		}
+		if target, ok := targets[i]; ok {
+			t := Piece{target, piece.Size, piece.Orientation}
+			lane, offset := t.Row(w), t.Col(w)
+			if piece.Orientation == Vertical {
+				lane, offset = t.Col(w), t.Row(w)
+			}
+			if lane != r.lane || offset < r.lo || offset > r.hi {
+				return nil, nil
+			}
+			r.lo, r.hi = offset, offset
+		}
+		ranges[i] = r
+	}
+	// place the pieces lane by lane, in order along each lane
+	sort.Slice(ranges, func(i, j int) bool {
+		a, b := ranges[i], ranges[j]
+		if a.orientation != b.orientation {
+			return a.orientation < b.orientation
+		}
+		if a.lane != b.lane {
+			return a.lane < b.lane
+		}
+		return a.offset < b.offset
+	})
+
+	var result []*Board
+	var err error
+	offsets := make([]int, len(board.Pieces))
+	var place func(int)
+	place = func(k int) {
+		if err != nil {
+			return
+		}
+		if k == len(ranges) {
+			pieces := make([]Piece, len(board.Pieces))
+			for i, piece := range board.Pieces {
+				pieces[i] = board.laneSlot(piece, offsets[i])
+			}
+			result = append(result, newBoard(w, h, pieces, board.Labels, board.Walls, board.Exit, board.ExitLane))
+			if n := s.limits.MaxMemoSize; n > 0 && len(result) > n {
+				err = ErrMemoLimit
+			} else if s.ctx != nil && len(result)%contextCheckInterval == 0 {
+				err = s.ctx.Err()
+			}
+			return
+		}
+		r := ranges[k]
+		piece := board.Pieces[r.piece]
+		lo := r.lo
+		if k > 0 {
+			// pieces cannot pass each other in a lane
+			p := ranges[k-1]
+			if p.orientation == r.orientation && p.lane == r.lane {
+				lo = maxInt(lo, offsets[p.piece]+board.Pieces[p.piece].Size)
+			}
+		}
+		for offset := lo; offset <= r.hi; offset++ {
+			slot := board.laneSlot(piece, offset)
+			stride := slot.Stride(w)
+			free := true
+			for j := 0; j < slot.Size; j++ {
+				if occupied[slot.Position+j*stride] {
+					free = false
+					break
+				}
+			}
+			if !free {
+				continue
+			}
+			for j := 0; j < slot.Size; j++ {
+				occupied[slot.Position+j*stride] = true
+			}
+			offsets[r.piece] = offset
+			place(k + 1)
+			for j := 0; j < slot.Size; j++ {
+				occupied[slot.Position+j*stride] = false
+			}
+		}
+	}
+	place(0)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// laneSlot returns piece moved along its lane to the given offset.
+func (board *Board) laneSlot(piece Piece, offset int) Piece {
+	w := board.Width
+	if piece.Orientation == Vertical {
+		piece.Position = offset*w + piece.Col(w)
+	} else {
+		piece.Position = piece.Row(w)*w + offset
+	}
+	return piece
+}
@@ -0,0 +1,44 @@
+package rush
+
+import "testing"
+
+func TestBidirectionalSolver(t *testing.T) {
+	for _, desc := range []string{
+		"BBBCDEFGGCDEF.AADEHHI....JI.KK.JLLMM",
+		"BB.C..D..C..DAAC..D.EE..F.....F.GGG.",
+		"..B.CC..B...AAB...DDD..E.....E.....E",
+		"..BBBD..E..DAAE..D..EHHHCC.F...GGF..",
+	} {
+		board, err := NewBoardFromString(desc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := board.Solve()
+		solution := NewBidirectionalSolver(board).Solve()
+		if solution.Solvable != expected.Solvable || solution.NumMoves != expected.NumMoves {
+			t.Fatalf("got %s, want %s", solution, expected)
+		}
+		if err := board.CheckSolution(solution.Moves); solution.Solvable && err != nil {
+			t.Fatal(err)
+		}
+		if solution.Solvable && solution.Nodes >= expected.Nodes {
+			t.Fatalf("expanded %d nodes, Solver searched %d", solution.Nodes, expected.Nodes)
+		}
+	}
+
+	// a goal moving another piece
+	board, err := NewBoardFromString("BB.C..D..C..DAAC..D.EE..F.....F.GGG.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	goal := PieceTargets{{0, board.Target()}, {1, 4}}
+	expected := NewSolverWithGoal(board, goal).Solve()
+	solution := NewBidirectionalSolverWithGoal(board, goal).Solve()
+	if !solution.Solvable || solution.NumMoves != expected.NumMoves {
+		t.Fatalf("got %s, want %s", solution, expected)
+	}
+	b := board.Copy()
+	if err := b.ApplyMoves(solution.Moves); err != nil || !goal.IsSolved(b) {
+		t.Fatalf("moves do not reach the goal: %v", err)
+	}
+}
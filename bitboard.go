@@ -0,0 +1,121 @@
+package rush
+
+import "math/bits"
+
+/*
+
+Boards with at most 64 cells track occupancy in a single uint64 instead of a
+[]bool, much like the C++ implementation. Cell i of the grid is bit i of the
+mask. A horizontal piece of size s at position p covers the bits
+
+	((1 << s) - 1) << p
+
+and a vertical piece covers the same pattern spaced w bits apart. Moving a
+piece is then two mask operations, and move generation shifts a single bit
+next to the piece along its lane until it hits something or falls off the
+edge of the grid.
+
+*/
+
+const maxBitboardCells = 64
+
+// bitMasks holds masks that depend only on the board size. It is shared by
+// all copies of a board.
+type bitMasks struct {
+	top    uint64   // top row
+	bottom uint64   // bottom row
+	vert   []uint64 // vertical piece of each size at position 0
+	col    []uint   // column of each cell
+}
+
+func newBitMasks(w, h int) *bitMasks {
+	if w*h > maxBitboardCells {
+		return nil
+	}
+	m := &bitMasks{}
+	for x := 0; x < w; x++ {
+		m.top |= 1 << uint(x)
+		m.bottom |= 1 << uint((h-1)*w+x)
+	}
+	m.col = make([]uint, w*h)
+	for i := range m.col {
+		m.col[i] = uint(i % w)
+	}
+	m.vert = make([]uint64, h+1)
+	for s := 1; s <= h; s++ {
+		m.vert[s] = m.vert[s-1] | 1<<uint((s-1)*w)
+	}
+	return m
+}
+
+func (board *Board) pieceMask(piece Piece) uint64 {
+	if piece.Orientation == Vertical {
+		return board.bits.vert[piece.Size] << uint(piece.Position)
+	}
+	return (uint64(1)<<uint(piece.Size) - 1) << uint(piece.Position)
+}
+
+func (board *Board) bitMoves(moves []Move) []Move {
+	m := board.bits
+	occupied := board.mask
+	w := board.Width
+	for i, piece := range board.Pieces {
+		p := uint(piece.Position)
+		s := uint(piece.Size)
+		if piece.Orientation == Horizontal {
+			// the free run on either side is a count of zero bits, with a
+			// sentinel bit set where the run would leave the row
+			col := m.col[p]
+			n := bits.LeadingZeros64(occupied<<(64-p) | 1<<(63-col))
+			for steps := -1; steps >= -n; steps-- {
+				moves = append(moves, Move{i, steps})
+			}
+			n = bits.TrailingZeros64(occupied>>(p+s) | 1<<(uint(w)-col-s))
+			for steps := 1; steps <= n; steps++ {
+				moves = append(moves, Move{i, steps})
+			}
+			continue
+		}
+		// vertical pieces walk a single bit up and down the column
+		shift := uint(w)
+		pm := m.vert[s] << p
+		if pm&m.top == 0 {
+			mask := pm >> shift &^ pm
+			for steps := -1; occupied&mask == 0; steps-- {
+				moves = append(moves, Move{i, steps})
+				if mask&m.top != 0 {
+					break
+				}
+				mask >>= shift
+			}
+		}
+		if pm&m.bottom == 0 {
+			mask := pm << shift &^ pm
+			for steps := 1; occupied&mask == 0; steps++ {
+				moves = append(moves, Move{i, steps})
+				if mask&m.bottom != 0 {
+					break
+				}
+				mask <<= shift
+			}
+		}
+	}
+	return moves
+}
+
+func (board *Board) bitDoMove(move Move) {
+	piece := &board.Pieces[move.Piece]
+	pm := board.pieceMask(*piece)
+	d := move.Steps
+	if piece.Orientation == Vertical {
+		d *= board.Width
+	}
+	board.mask &^= pm
+	if d > 0 {
+		board.mask |= pm << uint(d)
+	} else {
+		board.mask |= pm >> uint(-d)
+	}
+	piece.Position += d
+	board.moveKeyField(move.Piece, move.Steps)
+}
@@ -0,0 +1,282 @@
+package rush
+
+/*
+
+A Cluster is the set of states reachable from a board, which is the same set
+from any of its states since every move can be undone. It is a port of the
+C++ Cluster: one breadth first search forward finds every state, and one
+retrograde breadth first search from the solved states finds how far each
+state is from being solved. Along the way it records the fewest steps among
+the solutions with the fewest moves, which breaks ties between equally hard
+states and picks the solution returned for each.
+
+Unlike the C++ version the search does not stop at the first state that
+shows the board is not canonical, so every field is always filled in.
+
+*/
+
+type Cluster struct {
+	// Canonical is set if no state of the cluster sorts before the board it
+	// was built from, by MemoKey.Less including the primary piece.
+	Canonical bool
+
+	Solvable bool
+
+	// Minimal is set if removing any piece that the solution of Unsolved
+	// does not move would make it easier. It is only computed for the
+	// default goal.
+	Minimal bool
+
+	NumStates int
+
+	// Unsolved is the hardest state: the one furthest from being solved,
+	// then with the most steps, then first by MemoKey.Less. It is nil if the
+	// cluster is not solvable.
+	Unsolved *Board
+
+	// Distances counts the states by how many moves they are from being
+	// solved.
+	Distances []int
+
+	board  *Board
+	goal   Goal
+	states map[MemoKey]clusterState
+
+	// hardest holds every state as far from being solved as Unsolved.
+	hardest []*Board
+
+	// untouched holds the answers of PieceUntouched by piece.
+	untouched map[int]*untouchedCache
+}
+
+// clusterState is the distance of a state from being solved, or -1 if it
+// cannot be, and the fewest steps taken by a solution of that many moves.
+type clusterState struct {
+	distance int32
+	steps    int32
+}
+
+func NewCluster(board *Board) *Cluster {
+	return NewClusterWithGoal(board, DefaultGoal(board))
+}
+
+// NewClusterWithGoal returns the cluster of board with distances measured to
+// states satisfying goal.
+func NewClusterWithGoal(board *Board, goal Goal) *Cluster {
+	c, _ := buildCluster(board, goal, true, nil)
+	return c
+}
+
+// buildCluster searches the cluster of board, deciding whether it is minimal
+// only if asked. abort, if not nil, is called with the number of states seen
+// before each state is expanded and stops the search by returning an error.
+func buildCluster(board *Board, goal Goal, minimal bool, abort func(int) error) (*Cluster, error) {
+	input := board.Copy()
+	inputKey := *input.MemoKey()
+	c := &Cluster{Canonical: true, board: input.Copy(), goal: goal}
+	c.states = map[MemoKey]clusterState{inputKey: {-1, 0}}
+
+	// explore reachable states
+	queue := []*Board{input}
+	var solved []*Board
+	var buf []Move
+	for len(queue) > 0 {
+		b := queue[0]
+		queue[0] = nil
+		queue = queue[1:]
+		if abort != nil {
+			if err := abort(len(c.states)); err != nil {
+				return nil, err
+			}
+		}
+		if goal.IsSolved(b) {
+			c.Solvable = true
+			c.states[*b.MemoKey()] = clusterState{}
+			solved = append(solved, b)
+		}
+		buf = b.Moves(buf)
+		for _, move := range buf {
+			b.DoMove(move)
+			key := *b.MemoKey()
+			if _, ok := c.states[key]; !ok {
+				c.states[key] = clusterState{-1, 0}
+				if key.Less(&inputKey, true) {
+					c.Canonical = false
+				}
+				queue = append(queue, b.Copy())
+			}
+			b.UndoMove(move)
+		}
+	}
+	c.NumStates = len(c.states)
+	if !c.Solvable {
+		return c, nil
+	}
+
+	// determine how far each state is from a solved state, taking states in
+	// order of distance so that their steps are final when they are taken
+	queue = solved
+	var best clusterState
+	for len(queue) > 0 {
+		b := queue[0]
+		queue[0] = nil
+		queue = queue[1:]
+		s := c.states[*b.MemoKey()]
+		for len(c.Distances) <= int(s.distance) {
+			c.Distances = append(c.Distances, 0)
+		}
+		c.Distances[s.distance]++
+		if s.distance > best.distance {
+			c.hardest = c.hardest[:0]
+		}
+		c.hardest = append(c.hardest, b)
+		if c.Unsolved == nil || s.distance > best.distance ||
+			s.distance == best.distance && (s.steps > best.steps ||
+				s.steps == best.steps && b.MemoKey().Less(c.Unsolved.MemoKey(), true)) {
+			c.Unsolved = b
+			best = s
+		}
+		buf = b.Moves(buf)
+		for _, move := range buf {
+			b.DoMove(move)
+			key := *b.MemoKey()
+			t := c.states[key]
+			steps := s.steps + int32(move.AbsSteps())
+			if t.distance < 0 {
+				c.states[key] = clusterState{s.distance + 1, steps}
+				queue = append(queue, b.Copy())
+			} else if t.distance == s.distance+1 && steps < t.steps {
+				c.states[key] = clusterState{t.distance, steps}
+			}
+			b.UndoMove(move)
+		}
+	}
+
+	if minimal && isDefaultGoal(c.board, goal) {
+		c.Minimal = c.minimal()
+	}
+	return c, nil
+}
+
+// each calls f with every state of the cluster. The board passed to f is
+// only valid during the call. abort is called as for buildCluster.
+func (c *Cluster) each(f func(*Board), abort func(int) error) error {
+	board := c.board.Copy()
+	seen := map[MemoKey]bool{*board.MemoKey(): true}
+	queue := []*Board{board}
+	var buf []Move
+	for len(queue) > 0 {
+		b := queue[0]
+		queue[0] = nil
+		queue = queue[1:]
+		if abort != nil {
+			if err := abort(len(seen)); err != nil {
+				return err
+			}
+		}
+		f(b)
+		buf = b.Moves(buf)
+		for _, move := range buf {
+			b.DoMove(move)
+			if key := *b.MemoKey(); !seen[key] {
+				seen[key] = true
+				queue = append(queue, b.Copy())
+			}
+			b.UndoMove(move)
+		}
+	}
+	return nil
+}
+
+// minimal reports whether removing any piece that is not moved by the
+// solution of Unsolved would change its number of moves.
+func (c *Cluster) minimal() bool {
+	solution := c.Solution(c.Unsolved)
+	moved := make([]bool, len(c.Unsolved.Pieces))
+	for _, move := range solution.Moves {
+		moved[move.Piece] = true
+	}
+	for i := 1; i < len(moved); i++ {
+		if moved[i] {
+			continue
+		}
+		b := c.Unsolved.Copy()
+		b.RemovePiece(i)
+		if b.Solve().NumMoves == solution.NumMoves {
+			return false
+		}
+	}
+	return true
+}
+
+// NumMoves returns the number of moves needed to solve Unsolved, or -1 if
+// the cluster is not solvable.
+func (c *Cluster) NumMoves() int {
+	return len(c.Distances) - 1
+}
+
+// Distance returns the number of moves needed to solve board, or -1 if it
+// cannot be solved. The second result is false if board is not part of the
+// cluster.
+func (c *Cluster) Distance(board *Board) (int, bool) {
+	if !sameLayout(c.board, board) {
+		return 0, false
+	}
+	s, ok := c.states[*board.MemoKey()]
+	return int(s.distance), ok
+}
+
+// Solution returns the solution of board, which must be part of the
+// cluster, that takes the fewest steps among those with the fewest moves.
+func (c *Cluster) Solution(board *Board) Solution {
+	d, ok := c.Distance(board)
+	if !ok || d < 0 {
+		return Solution{MemoSize: c.NumStates}
+	}
+	board = board.Copy()
+	var moves []Move
+	var buf []Move
+	for {
+		var move Move
+		var ok bool
+		move, ok, buf = c.nextMove(board, buf)
+		if !ok {
+			break
+		}
+		board.DoMove(move)
+		moves = append(moves, move)
+	}
+	steps := 0
+	for _, move := range moves {
+		steps += move.AbsSteps()
+	}
+	return Solution{
+		Solvable: true,
+		Moves:    moves,
+		Labels:   board.Labels,
+		NumMoves: len(moves),
+		NumSteps: steps,
+		Cost:     len(moves),
+		Depth:    len(moves),
+		MemoSize: c.NumStates,
+	}
+}
+
+// nextMove returns the first move of the solution Solution gives for board,
+// which must be a solvable state of the cluster, or false if it is solved.
+func (c *Cluster) nextMove(board *Board, buf []Move) (Move, bool, []Move) {
+	s := c.states[*board.MemoKey()]
+	if s.distance <= 0 {
+		return Move{}, false, buf
+	}
+	buf = board.Moves(buf)
+	for _, move := range buf {
+		board.DoMove(move)
+		t := c.states[*board.MemoKey()]
+		board.UndoMove(move)
+		if t.distance == s.distance-1 && t.steps+int32(move.AbsSteps()) == s.steps {
+			return move, true, buf
+		}
+	}
+	panic("rush: cluster state has no move toward the goal")
+}
@@ -0,0 +1,72 @@
+package rush
+
+import "testing"
+
+func TestCluster(t *testing.T) {
+	board, err := NewBoardFromString("BB.C..D..C..DAAC..D.EE..F.....F.GGG.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster := NewCluster(board)
+	if cluster.NumStates != board.ReachableStates() {
+		t.Fatalf("got %d states, want %d", cluster.NumStates, board.ReachableStates())
+	}
+	if !cluster.Solvable || cluster.Unsolved == nil {
+		t.Fatal("expected solvable cluster")
+	}
+	total := 0
+	for _, n := range cluster.Distances {
+		total += n
+	}
+	if total != cluster.NumStates {
+		t.Fatalf("distances count %d states, want %d", total, cluster.NumStates)
+	}
+
+	want := cluster.Unsolved.Solve()
+	if want.NumMoves != cluster.NumMoves() {
+		t.Fatalf("got %d moves, want %d", cluster.NumMoves(), want.NumMoves)
+	}
+	solution := cluster.Solution(cluster.Unsolved)
+	if solution.NumMoves != want.NumMoves || solution.NumSteps > want.NumSteps {
+		t.Fatalf("got %s, want %s", solution, want)
+	}
+	if err := cluster.Unsolved.CheckSolution(solution.Moves); err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for b := range board.StateIterator() {
+		n++
+		if n%10 != 0 {
+			continue
+		}
+		d, ok := cluster.Distance(b)
+		if !ok || d != b.Solve().NumMoves || d > cluster.NumMoves() {
+			t.Fatalf("got distance %d for %s", d, b)
+		}
+	}
+
+	hardest, unsolved := board.Unsolve()
+	if d, _ := cluster.Distance(hardest); unsolved.NumMoves != cluster.NumMoves() || d != unsolved.NumMoves {
+		t.Fatalf("unsolve found %s, want %d moves", unsolved, cluster.NumMoves())
+	}
+
+	canonical := board.Canonicalize()
+	if !NewCluster(canonical).Canonical {
+		t.Fatal("expected canonical board")
+	}
+	if cluster.Canonical != (*canonical.MemoKey() == *board.MemoKey()) {
+		t.Fatalf("got canonical %v", cluster.Canonical)
+	}
+
+	other, err := NewBoardFromString("..BBBD..E..DAAE..D..EHHHCC.F...GGF..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster = NewCluster(other)
+	if cluster.Solvable || cluster.Unsolved != nil || cluster.NumMoves() != -1 {
+		t.Fatal("expected unsolvable cluster")
+	}
+	if cluster.NumStates != other.ReachableStates() {
+		t.Fatalf("got %d states, want %d", cluster.NumStates, other.ReachableStates())
+	}
+}
@@ -34,11 +34,7 @@ func main() {
 	fmt.Printf(" # steps: %d\n", solution.NumSteps)
 
 	// print out moves to solve puzzle
-	moveStrings := make([]string, len(solution.Moves))
-	for i, move := range solution.Moves {
-		moveStrings[i] = move.String()
-	}
-	fmt.Println(strings.Join(moveStrings, ", "))
+	fmt.Println(strings.Join(solution.MoveStrings(), ", "))
 
 	// solvable: true
 	//  # moves: 49
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/fogleman/rush"
+)
+
+// compares the plain iterative deepening search with each heuristic and
+// with the bidirectional search
+func main() {
+	args := os.Args[1:]
+	if len(args) < 1 {
+		fmt.Println("heuristics DESC...")
+		return
+	}
+
+	heuristics := []struct {
+		name      string
+		heuristic rush.Heuristic
+	}{
+		{"iddfs", nil},
+		{"blocker", rush.BlockerHeuristic{}},
+		{"static", rush.StaticHeuristic{SA: rush.NewStaticAnalyzer()}},
+	}
+
+	// one solver per heuristic is reused for every board
+	solvers := make([]*rush.Solver, len(heuristics))
+	for _, desc := range args {
+		board, err := rush.NewBoardFromString(desc)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(desc)
+		for i, h := range heuristics {
+			if solvers[i] == nil {
+				solvers[i] = rush.NewSolverWithHeuristic(board, h.heuristic)
+			} else {
+				solvers[i].Reset(board)
+			}
+			start := time.Now()
+			solution := solvers[i].Solve()
+			elapsed := time.Since(start)
+			fmt.Printf("%-8s %3d moves %10d nodes %8d states %v\n",
+				h.name, solution.NumMoves, solution.Nodes, solution.MemoSize, elapsed)
+		}
+		start := time.Now()
+		solution := rush.NewBidirectionalSolver(board).Solve()
+		elapsed := time.Since(start)
+		fmt.Printf("%-8s %3d moves %10d nodes %8d states %v\n",
+			"bidir", solution.NumMoves, solution.Nodes, solution.MemoSize, elapsed)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/fogleman/rush"
+)
+
+// hint serves GET /hint.json?board=DESC, where DESC is a board in the text
+// format read by NewBoardFromString, responding with the board's rush.Hint
+// as JSON:
+//
+//	{"solvable":true,"distance":12,"moves":["A+1","C-2"]}
+func hint(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	var board rush.Board
+	if err := board.UnmarshalText([]byte(r.URL.Query().Get("board"))); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(board.Hint()); err != nil {
+		log.Println(err)
+	}
+}
+
+func main() {
+	addr := flag.String("addr", "localhost:5001", "address to listen on")
+	flag.Parse()
+	http.HandleFunc("/hint.json", hint)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
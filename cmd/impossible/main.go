@@ -8,9 +8,7 @@ import (
 	"github.com/fogleman/rush"
 )
 
-type Key [rush.MaxPieces]rush.Piece
-
-func makeKey(board *rush.Board) Key {
+func makeKey(board *rush.Board) string {
 	pieces := make([]rush.Piece, len(board.Pieces))
 	copy(pieces, board.Pieces)
 	sort.Slice(pieces, func(i, j int) bool {
@@ -27,15 +25,11 @@ func makeKey(board *rush.Board) Key {
 		}
 		return a.Position < b.Position
 	})
-	var key Key
-	for i, piece := range pieces {
-		key[i] = piece
-	}
-	return key
+	return fmt.Sprint(pieces)
 }
 
 func main() {
-	seen := make(map[Key]bool)
+	seen := make(map[string]bool)
 	counter := 0
 	for i := 0; ; i++ {
 		board := rush.NewRandomBoard(6, 6, 2, 2, 4, 0)
@@ -51,12 +45,14 @@ func main() {
 		if board.Validate() != nil {
 			continue
 		}
-		solution := board.Solve()
-		if solution.Solvable {
+		// the solver's unsolvable verdict is a heuristic, so search every
+		// reachable state to be sure
+		proof, err := board.ProveUnsolvable()
+		if err != nil {
 			continue
 		}
 		gg.SavePNG(fmt.Sprintf("impossible-%d.png", counter), board.Render())
 		counter++
-		fmt.Println(counter)
+		fmt.Println(counter, proof.States)
 	}
 }
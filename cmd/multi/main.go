@@ -76,6 +76,7 @@ func worker(jobs <-chan EnumeratorItem, results chan<- Result) {
 		nonTrivialCount int
 		minimalCount    int
 	)
+	var unsolver *Unsolver
 	for job := range jobs {
 		jobCount++
 
@@ -89,7 +90,11 @@ func worker(jobs <-chan EnumeratorItem, results chan<- Result) {
 		canonicalCount++
 
 		// "unsolve" to find hardest reachable position
-		unsolver := NewUnsolverWithStaticAnalyzer(board, nil)
+		if unsolver == nil {
+			unsolver = NewUnsolver(board)
+		} else {
+			unsolver.Reset(board)
+		}
 		unsolved, solution := unsolver.UnsafeUnsolve()
 		unsolved.SortPieces()
 
@@ -1,18 +1,22 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"image"
 	"log"
-	"os"
 
 	"github.com/fogleman/gg"
 	"github.com/fogleman/rush"
 )
 
+var explain = flag.Bool("explain", false, "print and draw why static analysis finds the board impossible")
+
 func main() {
-	args := os.Args[1:]
+	flag.Parse()
+	args := flag.Args()
 	if len(args) != 1 && len(args) != 2 {
-		fmt.Println("render DESC [OUTPUT]")
+		fmt.Println("render [-explain] DESC [OUTPUT]")
 		return
 	}
 
@@ -25,7 +29,15 @@ func main() {
 	if len(args) == 2 {
 		output = args[1]
 	}
-	err = gg.SavePNG(output, board.Render())
+	var im image.Image
+	if *explain {
+		e := board.ExplainImpossible()
+		fmt.Println(e)
+		im = e.Render()
+	} else {
+		im = board.Render()
+	}
+	err = gg.SavePNG(output, im)
 	if err != nil {
 		log.Fatal(err)
 	}
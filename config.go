@@ -1,6 +1,8 @@
 package rush
 
-const MaxPieces = 18
 const MinPieceSize = 2
 const MinBoardSize = MinPieceSize + 1
-const MaxBoardSize = 16
+
+// MaxBoardSize bounds the width and height of a board, so that a board read
+// from untrusted input can't ask for a huge or overflowing number of cells.
+const MaxBoardSize = 1 << 10
@@ -0,0 +1,276 @@
+package rush
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+)
+
+/*
+
+Solver finds solutions with the fewest moves, and the number of steps is
+incidental. CostSolver instead minimizes the total cost of the moves under a
+CostModel, using A* search. MoveCost reproduces the move count, StepCost
+counts cells slid and WeightedCost charges each piece its own rate.
+
+A* keeps every state it reaches, so like BFSSolver it is best suited to
+boards of ordinary size.
+
+*/
+
+// CostModel prices moves. Costs must be positive. MinCost must return a
+// lower bound on the cost still needed to reach a state satisfying goal, or
+// zero if nothing better is known.
+type CostModel interface {
+	Cost(board *Board, move Move) int
+	MinCost(board *Board, goal Goal) int
+}
+
+// MoveCost charges 1 per move.
+type MoveCost struct{}
+
+func (MoveCost) Cost(board *Board, move Move) int {
+	return 1
+}
+
+func (MoveCost) MinCost(board *Board, goal Goal) int {
+	return goal.MinMoves(board)
+}
+
+// StepCost charges 1 per cell slid.
+type StepCost struct{}
+
+func (StepCost) Cost(board *Board, move Move) int {
+	return move.AbsSteps()
+}
+
+func (StepCost) MinCost(board *Board, goal Goal) int {
+	// the target piece must slide the whole way and every other piece that
+	// has to move slides at least one cell
+	return targetSteps(board, goal, 1) + maxInt(goal.MinMoves(board)-1, 0)
+}
+
+// WeightedCost charges Weights[i] for each move of piece i, or for each cell
+// slid if PerStep is set. Pieces without a weight cost 1. Weights must be
+// positive.
+type WeightedCost struct {
+	Weights []int
+	PerStep bool
+}
+
+// NewWeightedCost returns a WeightedCost with a copy of weights, or an error
+// if any of them is not positive.
+func NewWeightedCost(weights []int, perStep bool) (WeightedCost, error) {
+	c := WeightedCost{append([]int(nil), weights...), perStep}
+	return c, c.Validate()
+}
+
+// Validate checks that every weight is positive, as CostModel requires. A
+// negative weight would let a search lower its cost forever by moving a
+// piece back and forth.
+func (c WeightedCost) Validate() error {
+	for i, w := range c.Weights {
+		if w < 1 {
+			return fmt.Errorf("weight %d of piece %d must be positive", w, i)
+		}
+	}
+	return nil
+}
+
+func (c WeightedCost) weight(i int) int {
+	if i < len(c.Weights) {
+		return c.Weights[i]
+	}
+	return 1
+}
+
+func (c WeightedCost) Cost(board *Board, move Move) int {
+	w := c.weight(move.Piece)
+	if c.PerStep {
+		return w * move.AbsSteps()
+	}
+	return w
+}
+
+func (c WeightedCost) MinCost(board *Board, goal Goal) int {
+	// the cheapest piece bounds the cost of every move we cannot attribute
+	cheapest := c.weight(0)
+	for i := 1; i < len(board.Pieces); i++ {
+		cheapest = minInt(cheapest, c.weight(i))
+	}
+	if cheapest < 1 {
+		// invalid weights, see Validate
+		return 0
+	}
+	n := maxInt(goal.MinMoves(board)-1, 0)
+	if t, ok := goal.(PieceTarget); ok && !t.IsSolved(board) {
+		if c.PerStep {
+			return targetSteps(board, t, c.weight(t.Piece)) + n*cheapest
+		}
+		return c.weight(t.Piece) + n*cheapest
+	}
+	return goal.MinMoves(board) * cheapest
+}
+
+// targetSteps returns the number of cells the target piece of goal still
+// has to slide, times weight, or zero if goal is not a PieceTarget.
+func targetSteps(board *Board, goal Goal, weight int) int {
+	t, ok := goal.(PieceTarget)
+	if !ok || !board.inLane(t.Piece, t.Position) {
+		return 0
+	}
+	_, _, n := board.piecePath(t.Piece, t.Position)
+	return n * weight
+}
+
+type CostSolver struct {
+	board *Board
+	goal  Goal
+	cost  CostModel
+	ctx   context.Context
+}
+
+func NewCostSolver(board *Board, cost CostModel) *CostSolver {
+	return NewCostSolverWithGoal(board, DefaultGoal(board), cost)
+}
+
+// NewCostSolverWithGoal returns a CostSolver that searches for the cheapest
+// way to a state satisfying goal.
+func NewCostSolverWithGoal(board *Board, goal Goal, cost CostModel) *CostSolver {
+	return &CostSolver{board: board, goal: goal, cost: cost}
+}
+
+// costNode is a state on the A* open list. Its moves are found by following
+// parent links back to the start.
+type costNode struct {
+	board  *Board
+	cost   int // cost so far
+	bound  int // cost so far plus the lower bound on the rest
+	move   Move
+	parent *costNode
+}
+
+type costQueue []*costNode
+
+func (q costQueue) Len() int {
+	return len(q)
+}
+
+func (q costQueue) Less(i, j int) bool {
+	if q[i].bound != q[j].bound {
+		return q[i].bound < q[j].bound
+	}
+	// prefer nodes closer to the goal
+	return q[i].cost > q[j].cost
+}
+
+func (q costQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+}
+
+func (q *costQueue) Push(x interface{}) {
+	*q = append(*q, x.(*costNode))
+}
+
+func (q *costQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	x := old[n-1]
+	*q = old[:n-1]
+	return x
+}
+
+func (s *CostSolver) Solve() Solution {
+	board := s.board
+	if err := board.Validate(); err != nil {
+		return Solution{}
+	}
+	if c, ok := s.cost.(WeightedCost); ok && c.Validate() != nil {
+		return Solution{}
+	}
+	if isDefaultGoal(board, s.goal) && board.Impossible() {
+		return Solution{}
+	}
+
+	best := make(map[MemoKey]int)
+	best[*board.MemoKey()] = 0
+	q := costQueue{{board.Copy(), 0, s.cost.MinCost(board, s.goal), Move{}, nil}}
+	var nodes uint64
+	var hits uint64
+	var buf []Move
+	for len(q) > 0 {
+		node := heap.Pop(&q).(*costNode)
+		b := node.board
+		if best[*b.MemoKey()] < node.cost {
+			// a cheaper way here was found after this node was queued
+			continue
+		}
+		nodes++
+		if s.ctx != nil && nodes%contextCheckInterval == 0 {
+			if err := s.ctx.Err(); err != nil {
+				return Solution{
+					Cost:     node.bound,
+					MemoSize: len(best),
+					MemoHits: hits,
+					Nodes:    nodes,
+					Aborted:  err,
+				}
+			}
+		}
+		if s.goal.IsSolved(b) {
+			return s.solution(node, len(best), hits, nodes)
+		}
+		buf = b.Moves(buf)
+		for _, move := range buf {
+			cost := node.cost + s.cost.Cost(b, move)
+			b.DoMove(move)
+			hits++
+			key := *b.MemoKey()
+			if c, ok := best[key]; !ok || cost < c {
+				best[key] = cost
+				child := b.Copy()
+				bound := cost + s.cost.MinCost(child, s.goal)
+				heap.Push(&q, &costNode{child, cost, bound, move, node})
+			}
+			b.UndoMove(move)
+		}
+		// expanded nodes are only needed for their moves
+		node.board = nil
+	}
+	return Solution{MemoSize: len(best), MemoHits: hits, Nodes: nodes}
+}
+
+// SolveContext is like Solve but stops early, returning a Solution with
+// Aborted set, if ctx is done. Cost is then a lower bound on the cost of a
+// solution.
+func (s *CostSolver) SolveContext(ctx context.Context) Solution {
+	s.ctx = ctx
+	defer func() { s.ctx = nil }()
+	return s.Solve()
+}
+
+func (s *CostSolver) solution(node *costNode, memoSize int, hits, nodes uint64) Solution {
+	var moves []Move
+	for n := node; n.parent != nil; n = n.parent {
+		moves = append(moves, n.move)
+	}
+	steps := 0
+	for i, j := 0, len(moves)-1; i < j; i, j = i+1, j-1 {
+		moves[i], moves[j] = moves[j], moves[i]
+	}
+	for _, move := range moves {
+		steps += move.AbsSteps()
+	}
+	return Solution{
+		Solvable: true,
+		Moves:    moves,
+		Labels:   s.board.Labels,
+		NumMoves: len(moves),
+		NumSteps: steps,
+		Cost:     node.cost,
+		Depth:    len(moves),
+		MemoSize: memoSize,
+		MemoHits: hits,
+		Nodes:    nodes,
+	}
+}
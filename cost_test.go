@@ -0,0 +1,78 @@
+package rush
+
+import "testing"
+
+func TestCostSolver(t *testing.T) {
+	board, err := NewBoardFromString("BBBCDEFGGCDEF.AADEHHI....JI.KK.JLLMM")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := board.Solve()
+	goal := DefaultGoal(board)
+	// a GoalFunc gives no lower bound, so searching with it is plain
+	// Dijkstra and checks that the A* bounds are admissible
+	dijkstra := GoalFunc(goal.IsSolved)
+	models := []CostModel{
+		MoveCost{},
+		StepCost{},
+		WeightedCost{[]int{1, 3, 2, 5}, false},
+		WeightedCost{[]int{4, 1, 3}, true},
+	}
+	for _, model := range models {
+		solution := NewCostSolver(board, model).Solve()
+		if err := board.CheckSolution(solution.Moves); err != nil {
+			t.Fatal(err)
+		}
+		cost := 0
+		b := board.Copy()
+		for _, move := range solution.Moves {
+			cost += model.Cost(b, move)
+			b.DoMove(move)
+		}
+		if cost != solution.Cost {
+			t.Fatalf("%T: moves cost %d, solution reports %d", model, cost, solution.Cost)
+		}
+		other := NewCostSolverWithGoal(board, dijkstra, model).Solve()
+		if other.Cost != solution.Cost {
+			t.Fatalf("%T: got cost %d, Dijkstra found %d", model, solution.Cost, other.Cost)
+		}
+		switch model.(type) {
+		case MoveCost:
+			if solution.Cost != expected.NumMoves {
+				t.Fatalf("got %d moves, want %d", solution.Cost, expected.NumMoves)
+			}
+		case StepCost:
+			if solution.Cost != solution.NumSteps || solution.NumSteps > expected.NumSteps {
+				t.Fatalf("got %d steps, Solve took %d", solution.NumSteps, expected.NumSteps)
+			}
+		}
+	}
+}
+
+func TestWeightedCostValidate(t *testing.T) {
+	board, err := NewBoardFromString("BBBCDEFGGCDEF.AADEHHI....JI.KK.JLLMM")
+	if err != nil {
+		t.Fatal(err)
+	}
+	weights := []int{1, 3, 2, 5}
+	model, err := NewWeightedCost(weights, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	weights[0] = 0
+	if model.Weights[0] != 1 {
+		t.Fatal("expected the weights to be copied")
+	}
+	for _, weights := range [][]int{{0, 1}, {1, 2, -3}} {
+		if _, err := NewWeightedCost(weights, false); err == nil {
+			t.Fatalf("expected error for weights %v", weights)
+		}
+		model := WeightedCost{weights, false}
+		if m := model.MinCost(board, DefaultGoal(board)); m != 0 {
+			t.Fatalf("got lower bound %d for weights %v", m, weights)
+		}
+		if solution := NewCostSolver(board, model).Solve(); solution.Solvable {
+			t.Fatalf("expected no solution for weights %v", weights)
+		}
+	}
+}
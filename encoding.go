@@ -0,0 +1,307 @@
+package rush
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Orientation and Exit are encoded by name.
+
+func (orientation Orientation) MarshalText() ([]byte, error) {
+	if orientation != Horizontal && orientation != Vertical {
+		return nil, fmt.Errorf("invalid orientation %d", int(orientation))
+	}
+	return []byte(orientation.String()), nil
+}
+
+func (orientation *Orientation) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "horizontal":
+		*orientation = Horizontal
+	case "vertical":
+		*orientation = Vertical
+	default:
+		return fmt.Errorf("invalid orientation %q", text)
+	}
+	return nil
+}
+
+func (exit Exit) MarshalText() ([]byte, error) {
+	if exit < ExitRight || exit > ExitBottom {
+		return nil, fmt.Errorf("invalid exit %d", int(exit))
+	}
+	return []byte(exit.String()), nil
+}
+
+func (exit *Exit) UnmarshalText(text []byte) error {
+	for e := ExitRight; e <= ExitBottom; e++ {
+		if string(text) == e.String() {
+			*exit = e
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid exit %q", text)
+}
+
+// Moves are encoded in the same "A+1" notation produced by Move.String.
+// Moves are always encoded with the default A, B, C, etc. labels since a
+// Move does not know which board it belongs to. Solution uses its own labels
+// when encoding its moves.
+
+func (move Move) MarshalText() ([]byte, error) {
+	if move.Piece < 0 || move.Piece >= len(labelChars) {
+		return nil, fmt.Errorf("move piece %d has no label", move.Piece)
+	}
+	if move.Steps == 0 {
+		return nil, fmt.Errorf("move must have non-zero steps")
+	}
+	return []byte(move.String()), nil
+}
+
+func (move *Move) UnmarshalText(text []byte) error {
+	m, err := ParseMove(string(text))
+	if err != nil {
+		return err
+	}
+	*move = m
+	return nil
+}
+
+// Boards are encoded as text in the format read by NewBoardFromString, using
+// the board's labels and followed by ":exit" when the exit differs from the
+// default for the primary piece's orientation, or ":exit:lane" when the exit
+// has a lane of its own. The text has no room to say which piece is primary,
+// so boards whose primary piece NewBoard would not pick from the labels can
+// only be encoded as JSON. The JSON encoding spells out every field and also
+// preserves the order of the pieces.
+
+func (board *Board) MarshalText() ([]byte, error) {
+	if err := board.Validate(); err != nil {
+		return nil, err
+	}
+	labels := make([]string, len(board.Pieces))
+	for i := range labels {
+		labels[i] = board.Label(i)
+	}
+	if primary := primaryLabel(labels); primary != labels[0] {
+		return nil, fmt.Errorf("board text would make %s the primary piece, not %s", primary, labels[0])
+	}
+	text := strings.Replace(board.String(), "\n", "/", -1)
+	if board.Width == board.Height {
+		text = strings.Replace(text, "/", "", -1)
+	}
+	if board.ExitLane != nil {
+		text += fmt.Sprintf(":%s:%d", board.Exit, *board.ExitLane)
+	} else if board.Exit != defaultExit(board.Pieces[0].Orientation) {
+		text += ":" + board.Exit.String()
+	}
+	return []byte(text), nil
+}
+
+func (board *Board) UnmarshalText(text []byte) error {
+	b, err := NewBoardFromString(string(text))
+	if err != nil {
+		return err
+	}
+	*board = *b
+	return nil
+}
+
+type pieceJSON struct {
+	Label       string      `json:"label"`
+	Position    int         `json:"position"`
+	Size        int         `json:"size"`
+	Orientation Orientation `json:"orientation"`
+}
+
+type boardJSON struct {
+	Width    int         `json:"width"`
+	Height   int         `json:"height"`
+	Exit     *Exit       `json:"exit,omitempty"`
+	ExitLane *int        `json:"exitLane,omitempty"`
+	Pieces   []pieceJSON `json:"pieces"`
+	Walls    []int       `json:"walls"`
+}
+
+func (board *Board) MarshalJSON() ([]byte, error) {
+	if err := board.Validate(); err != nil {
+		return nil, err
+	}
+	exit := board.Exit
+	b := boardJSON{
+		Width:    board.Width,
+		Height:   board.Height,
+		Exit:     &exit,
+		ExitLane: board.ExitLane,
+		Pieces:   make([]pieceJSON, len(board.Pieces)),
+		Walls:    make([]int, len(board.Walls)),
+	}
+	for i, piece := range board.Pieces {
+		b.Pieces[i] = pieceJSON{board.Label(i), piece.Position, piece.Size, piece.Orientation}
+	}
+	copy(b.Walls, board.Walls)
+	return json.Marshal(b)
+}
+
+func (board *Board) UnmarshalJSON(data []byte) error {
+	var b boardJSON
+	if err := json.Unmarshal(data, &b); err != nil {
+		return err
+	}
+	pieces := make([]Piece, len(b.Pieces))
+	labels := make([]string, len(b.Pieces))
+	for i, p := range b.Pieces {
+		pieces[i] = Piece{p.Position, p.Size, p.Orientation}
+		labels[i] = p.Label
+		if p.Label == "" {
+			labels[i] = defaultLabel(i)
+		}
+	}
+	if isDefaultLabels(labels) {
+		labels = nil
+	}
+	result := Board{Width: b.Width, Height: b.Height, Pieces: pieces, Labels: labels, Walls: b.Walls, ExitLane: b.ExitLane}
+	if b.Exit != nil {
+		result.Exit = *b.Exit
+	} else if len(pieces) > 0 {
+		result.Exit = defaultExit(pieces[0].Orientation)
+	}
+	if err := result.Validate(); err != nil {
+		return err
+	}
+	*board = *newBoard(result.Width, result.Height, pieces, labels, result.Walls, result.Exit, result.ExitLane)
+	return nil
+}
+
+// Solutions are encoded as JSON objects with the move list in "A+1" notation.
+
+type solutionJSON struct {
+	Solvable      bool             `json:"solvable"`
+	Labels        []string         `json:"labels,omitempty"`
+	Moves         []string         `json:"moves"`
+	NumMoves      *int             `json:"numMoves,omitempty"`
+	NumSteps      *int             `json:"numSteps,omitempty"`
+	Cost          int              `json:"cost,omitempty"`
+	Depth         int              `json:"depth,omitempty"`
+	MemoSize      int              `json:"memoSize,omitempty"`
+	MemoHits      uint64           `json:"memoHits,omitempty"`
+	MemoMisses    uint64           `json:"memoMisses,omitempty"`
+	MemoEvictions uint64           `json:"memoEvictions,omitempty"`
+	Nodes         uint64           `json:"nodes,omitempty"`
+	Aborted       string           `json:"aborted,omitempty"`
+	Proof         *UnsolvableProof `json:"proof,omitempty"`
+}
+
+func (solution Solution) MarshalJSON() ([]byte, error) {
+	for _, move := range solution.Moves {
+		if move.Steps == 0 {
+			return nil, fmt.Errorf("move must have non-zero steps")
+		}
+	}
+	var aborted string
+	if solution.Aborted != nil {
+		aborted = solution.Aborted.Error()
+	}
+	return json.Marshal(solutionJSON{
+		solution.Solvable, solution.Labels, solution.MoveStrings(),
+		&solution.NumMoves, &solution.NumSteps, solution.Cost,
+		solution.Depth, solution.MemoSize, solution.MemoHits,
+		solution.MemoMisses, solution.MemoEvictions, solution.Nodes,
+		aborted, solution.Proof})
+}
+
+func (solution *Solution) UnmarshalJSON(data []byte) error {
+	var s solutionJSON
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if !s.Solvable && len(s.Moves) > 0 {
+		return fmt.Errorf("unsolvable solution must not have moves")
+	}
+	if s.Solvable && s.Proof != nil {
+		return fmt.Errorf("solvable solution must not have an unsolvability proof")
+	}
+	moves, err := parseMoves(strings.Join(s.Moves, " "), s.Labels)
+	if err != nil {
+		return err
+	}
+	steps := 0
+	for _, move := range moves {
+		steps += move.AbsSteps()
+	}
+	// counts are optional but must agree with the moves when present
+	if s.NumMoves != nil && *s.NumMoves != len(s.Moves) {
+		return fmt.Errorf("numMoves is %d but there are %d moves", *s.NumMoves, len(s.Moves))
+	}
+	if s.NumSteps != nil && *s.NumSteps != steps {
+		return fmt.Errorf("numSteps is %d but the moves take %d steps", *s.NumSteps, steps)
+	}
+	if len(moves) == 0 {
+		moves = nil
+	}
+	*solution = Solution{
+		Solvable:      s.Solvable,
+		Moves:         moves,
+		Labels:        s.Labels,
+		NumMoves:      len(moves),
+		NumSteps:      steps,
+		Cost:          s.Cost,
+		Depth:         s.Depth,
+		MemoSize:      s.MemoSize,
+		MemoHits:      s.MemoHits,
+		MemoMisses:    s.MemoMisses,
+		MemoEvictions: s.MemoEvictions,
+		Nodes:         s.Nodes,
+		Proof:         s.Proof,
+	}
+	if s.Aborted != "" {
+		solution.Aborted = errors.New(s.Aborted)
+	}
+	return nil
+}
+
+// Hints are encoded like solutions, with the moves that each start an
+// optimal solution and the distance left, so that a player can show them.
+
+type hintJSON struct {
+	Solvable bool     `json:"solvable"`
+	Distance int      `json:"distance"`
+	Labels   []string `json:"labels,omitempty"`
+	Moves    []string `json:"moves"`
+}
+
+func (hint Hint) MarshalJSON() ([]byte, error) {
+	for _, move := range hint.Moves {
+		if move.Steps == 0 {
+			return nil, fmt.Errorf("move must have non-zero steps")
+		}
+	}
+	return json.Marshal(hintJSON{hint.Solvable, hint.Distance, hint.Labels, hint.MoveStrings()})
+}
+
+func (hint *Hint) UnmarshalJSON(data []byte) error {
+	var h hintJSON
+	if err := json.Unmarshal(data, &h); err != nil {
+		return err
+	}
+	if (!h.Solvable || h.Distance == 0) && len(h.Moves) > 0 {
+		return fmt.Errorf("hint for an unsolvable or solved board must not have moves")
+	}
+	if h.Solvable && h.Distance > 0 && len(h.Moves) == 0 {
+		return fmt.Errorf("hint for an unsolved board must have moves")
+	}
+	if h.Distance < 0 {
+		return fmt.Errorf("invalid distance %d", h.Distance)
+	}
+	moves, err := parseMoves(strings.Join(h.Moves, " "), h.Labels)
+	if err != nil {
+		return err
+	}
+	if len(moves) == 0 {
+		moves = nil
+	}
+	*hint = Hint{h.Solvable, h.Distance, moves, h.Labels}
+	return nil
+}
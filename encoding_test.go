@@ -0,0 +1,164 @@
+package rush
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestBoardJSON(t *testing.T) {
+	board, err := NewBoardFromString("BBBCDEFGGCDEF.AADEHHI....JI.KK.JLLMM")
+	if err != nil {
+		t.Fatal(err)
+	}
+	board.AddWall(22)
+	board.Exit = ExitLeft
+	data, err := json.Marshal(board)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var other Board
+	if err := json.Unmarshal(data, &other); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(board, &other) {
+		t.Fatalf("round trip mismatch:\n%s\n%s", board, &other)
+	}
+
+	text, err := board.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(text) != board.Hash()+":left" {
+		t.Fatalf("unexpected text %s", text)
+	}
+
+	// an exit lane of its own round trips through text and JSON
+	lane := 2
+	board.ExitLane = &lane
+	text, err = board.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(text) != board.Hash()+":left:2" {
+		t.Fatalf("unexpected text %s", text)
+	}
+	other = Board{}
+	if err := other.UnmarshalText(text); err != nil || !reflect.DeepEqual(board, &other) {
+		t.Fatalf("text round trip mismatch: %v", err)
+	}
+	if data, err = json.Marshal(board); err != nil {
+		t.Fatal(err)
+	}
+	other = Board{}
+	if err := json.Unmarshal(data, &other); err != nil || !reflect.DeepEqual(board, &other) {
+		t.Fatalf("JSON round trip mismatch: %v", err)
+	}
+
+	// decoding validates the board
+	bad := `{"width":6,"height":6,"pieces":[{"position":12,"size":2,"orientation":"vertical"}]}`
+	if err := json.Unmarshal([]byte(bad), &other); err != nil {
+		t.Fatalf("vertical primary should default to bottom exit: %v", err)
+	}
+	bad = `{"width":6,"height":6,"exit":"right","pieces":[{"position":12,"size":2,"orientation":"vertical"}]}`
+	if err := json.Unmarshal([]byte(bad), &other); err == nil {
+		t.Fatal("expected error for vertical primary with right exit")
+	}
+	bad = `{"width":6,"height":6,"exitLane":6,"pieces":[{"position":12,"size":2,"orientation":"horizontal"}]}`
+	if err := json.Unmarshal([]byte(bad), &other); err == nil {
+		t.Fatal("expected error for exit lane outside of the grid")
+	}
+
+	// huge or overflowing sizes are rejected before any cells are allocated
+	for _, size := range []string{`"width":1125899906842624,"height":3`, `"width":6,"height":1099511627776`} {
+		bad = `{` + size + `,"exit":"bottom","pieces":[{"position":0,"size":2,"orientation":"vertical"}],"walls":[]}`
+		if err := json.Unmarshal([]byte(bad), &other); err == nil {
+			t.Fatalf("expected error for %s", size)
+		}
+	}
+}
+
+func TestBoardText(t *testing.T) {
+	// labels other than A, B, C, etc. round trip when the primary piece is
+	// the one NewBoard picks: A, or R, or else the first in sorted order
+	for _, desc := range []string{
+		"....Q./....Q./RRZ.../..Z.../XXX.../......",
+		"P.k.../P.k.../MM..../....../....../......",
+		"....B./....B./AA..C./....C./....../......:left:2",
+	} {
+		board, err := NewBoardFromString(desc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		text, err := board.MarshalText()
+		if err != nil {
+			t.Fatalf("%s: %v", desc, err)
+		}
+		var other Board
+		if err := other.UnmarshalText(text); err != nil || !reflect.DeepEqual(board, &other) {
+			t.Fatalf("%s: text round trip mismatch: %s %v", desc, text, err)
+		}
+	}
+
+	// a primary piece NewBoard would not pick can't be encoded as text
+	board, err := NewBoardFromString("....B.....B.AA......................")
+	if err != nil {
+		t.Fatal(err)
+	}
+	board.Labels = []string{"B", "A"}
+	if text, err := board.MarshalText(); err == nil {
+		t.Fatalf("expected error, got %s", text)
+	}
+}
+
+func TestSolutionJSON(t *testing.T) {
+	board, err := NewBoardFromString("..B.CC..B...AAB...DDD..E.....E.....E")
+	if err != nil {
+		t.Fatal(err)
+	}
+	solution := board.Solve()
+	data, err := json.Marshal(solution)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var other Solution
+	if err := json.Unmarshal(data, &other); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(solution, other) {
+		t.Fatalf("round trip mismatch: %s", data)
+	}
+
+	bad := `{"solvable":true,"moves":["A+1","B-2"],"numMoves":3}`
+	if err := json.Unmarshal([]byte(bad), &other); err == nil {
+		t.Fatal("expected error for inconsistent numMoves")
+	}
+	bad = `{"solvable":true,"moves":["A+0"]}`
+	if err := json.Unmarshal([]byte(bad), &other); err == nil {
+		t.Fatal("expected error for zero step move")
+	}
+}
+
+func TestHintJSON(t *testing.T) {
+	board, err := NewBoardFromString("..B.CC..B...AAB...DDD..E.....E.....E")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hint := board.Hint()
+	data, err := json.Marshal(hint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var other Hint
+	if err := json.Unmarshal(data, &other); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(hint, other) {
+		t.Fatalf("round trip mismatch: %s", data)
+	}
+
+	bad := `{"solvable":false,"distance":0,"moves":["A+1"]}`
+	if err := json.Unmarshal([]byte(bad), &other); err == nil {
+		t.Fatal("expected error for unsolvable hint with moves")
+	}
+}
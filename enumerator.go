@@ -56,7 +56,8 @@ type EnumeratorItem struct {
 type Enumerator struct {
 	width       int
 	height      int
-	primaryRow  int
+	exit        Exit
+	primaryLane int
 	primarySize int
 	minSize     int
 	maxSize     int
@@ -66,11 +67,15 @@ type Enumerator struct {
 	colEntries  [][]positionEntry
 }
 
-func NewEnumerator(w, h, pr, ps, mins, maxs int) *Enumerator {
+// NewEnumeratorWithExit returns an Enumerator for boards whose primary piece
+// leaves through the given exit. The primary lane is a row for ExitRight and
+// ExitLeft or a column for ExitTop and ExitBottom.
+func NewEnumeratorWithExit(w, h int, exit Exit, lane, ps, mins, maxs int) *Enumerator {
 	e := Enumerator{}
 	e.width = w
 	e.height = h
-	e.primaryRow = pr
+	e.exit = exit
+	e.primaryLane = lane
 	e.primarySize = ps
 	e.minSize = mins
 	e.maxSize = maxs
@@ -84,6 +89,10 @@ func NewEnumerator(w, h, pr, ps, mins, maxs int) *Enumerator {
 	return &e
 }
 
+func NewEnumerator(w, h, pr, ps, mins, maxs int) *Enumerator {
+	return NewEnumeratorWithExit(w, h, ExitRight, pr, ps, mins, maxs)
+}
+
 func NewDefaultEnumerator() *Enumerator {
 	return NewEnumerator(6, 6, 2, 2, 2, 3)
 }
@@ -91,7 +100,7 @@ func NewDefaultEnumerator() *Enumerator {
 func (e *Enumerator) Enumerate(channelBufferSize int) <-chan EnumeratorItem {
 	ch := make(chan EnumeratorItem, channelBufferSize)
 	go func() {
-		e.populatePrimaryRow(ch)
+		e.populatePrimaryLane(ch)
 		close(ch)
 	}()
 	return ch
@@ -111,7 +120,36 @@ func (e *Enumerator) Count() uint64 {
 	// 4x4 = 695
 	// 5x5 = 124886
 	// 6x6 = 88914655
-	return e.countPrimaryRow()
+	return e.countPrimaryLane()
+}
+
+func (e *Enumerator) isPrimaryRow(y int) bool {
+	return e.exit.Orientation() == Horizontal && y == e.primaryLane
+}
+
+func (e *Enumerator) isPrimaryCol(x int) bool {
+	return e.exit.Orientation() == Vertical && x == e.primaryLane
+}
+
+// isPrimaryLane reports whether pieces form a valid primary lane: just the
+// primary piece, sitting at its target.
+func (e *Enumerator) isPrimaryLane(pieces []Piece) bool {
+	if len(pieces) != 1 {
+		return false
+	}
+	piece := pieces[0]
+	if piece.Size != e.primarySize {
+		return false
+	}
+	board := Board{Width: e.width, Height: e.height, Pieces: pieces, Exit: e.exit}
+	return piece.Position == board.Target()
+}
+
+func (e *Enumerator) primaryEntries() []positionEntry {
+	if e.exit.Orientation() == Vertical {
+		return e.colEntries[e.primaryLane]
+	}
+	return e.rowEntries[e.primaryLane]
 }
 
 func (e *Enumerator) precomputeGroups(sizes []int, sum int) {
@@ -134,18 +172,8 @@ func (e *Enumerator) precomputeGroups(sizes []int, sum int) {
 func (e *Enumerator) precomputeRow(y, x int, pieces []Piece) {
 	w := e.width
 	if x >= w {
-		if y == e.primaryRow {
-			if len(pieces) != 1 {
-				return
-			}
-			if pieces[0].Size != e.primarySize {
-				return
-			}
-			piece := pieces[0]
-			target := (piece.Row(w)+1)*w - piece.Size
-			if piece.Position != target {
-				return
-			}
+		if e.isPrimaryRow(y) && !e.isPrimaryLane(pieces) {
+			return
 		}
 		var n int
 		for _, piece := range pieces {
@@ -174,6 +202,9 @@ func (e *Enumerator) precomputeCol(x, y int, pieces []Piece) {
 	w := e.width
 	h := e.height
 	if y >= h {
+		if e.isPrimaryCol(x) && !e.isPrimaryLane(pieces) {
+			return
+		}
 		var n int
 		for _, piece := range pieces {
 			n += piece.Size
@@ -215,10 +246,11 @@ func (e *Enumerator) precomputePositionEntries() {
 	}
 }
 
-func (e *Enumerator) populatePrimaryRow(ch chan EnumeratorItem) {
+func (e *Enumerator) populatePrimaryLane(ch chan EnumeratorItem) {
 	var counter uint64
 	board := NewEmptyBoard(e.width, e.height)
-	for _, pe := range e.rowEntries[e.primaryRow] {
+	board.Exit = e.exit
+	for _, pe := range e.primaryEntries() {
 		for _, piece := range pe.Pieces {
 			board.addPiece(piece)
 		}
@@ -234,7 +266,7 @@ func (e *Enumerator) populateRow(ch chan EnumeratorItem, counter *uint64, y int,
 		e.populateCol(ch, counter, 0, mask, require, group, board)
 		return
 	}
-	if y == e.primaryRow {
+	if e.isPrimaryRow(y) {
 		e.populateRow(ch, counter, y+1, mask, require, group, board)
 		return
 	}
@@ -262,6 +294,10 @@ func (e *Enumerator) populateCol(ch chan EnumeratorItem, counter *uint64, x int,
 		ch <- EnumeratorItem{board.Copy(), group, *counter}
 		return
 	}
+	if e.isPrimaryCol(x) {
+		e.populateCol(ch, counter, x+1, mask, require, group, board)
+		return
+	}
 	group *= len(e.groups)
 	for _, pe := range e.colEntries[x] {
 		if mask&pe.Mask != 0 {
@@ -277,9 +313,9 @@ func (e *Enumerator) populateCol(ch chan EnumeratorItem, counter *uint64, x int,
 	}
 }
 
-func (e *Enumerator) countPrimaryRow() uint64 {
+func (e *Enumerator) countPrimaryLane() uint64 {
 	var counter uint64
-	for _, pe := range e.rowEntries[e.primaryRow] {
+	for _, pe := range e.primaryEntries() {
 		e.countRow(0, pe.Mask, 0, &counter)
 	}
 	return counter
@@ -290,7 +326,7 @@ func (e *Enumerator) countRow(y int, mask, require uint64, counter *uint64) {
 		e.countCol(0, mask, require, counter)
 		return
 	}
-	if y == e.primaryRow {
+	if e.isPrimaryRow(y) {
 		e.countRow(y+1, mask, require, counter)
 		return
 	}
@@ -310,6 +346,10 @@ func (e *Enumerator) countCol(x int, mask, require uint64, counter *uint64) {
 		*counter++
 		return
 	}
+	if e.isPrimaryCol(x) {
+		e.countCol(x+1, mask, require, counter)
+		return
+	}
 	for _, pe := range e.colEntries[x] {
 		if mask&pe.Mask != 0 {
 			continue
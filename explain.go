@@ -0,0 +1,219 @@
+package rush
+
+import (
+	"fmt"
+	"image"
+	"strings"
+)
+
+/*
+
+Impossible only says whether static analysis rejects a board. Explain runs
+the same analysis but also records each deduction it makes: which row or
+column was examined, in which round of propagation, which pieces are in it,
+which of its squares were already blocked from the other direction, and which
+squares it blocks as a result. Chain then follows the deductions back from
+the blocked square on the primary piece's path, keeping only those that the
+verdict depends on, so that a rejected puzzle can be shown with its reasons.
+
+*/
+
+// StaticDeduction is one row or column examined during static analysis. A
+// row holds horizontal pieces and a column holds vertical ones. Squares are
+// board indices.
+type StaticDeduction struct {
+	Step        int
+	Orientation Orientation // Horizontal for a row, Vertical for a column
+	Line        int         // the row or column
+	Pieces      []int       // the pieces in the line
+	Given       []int       // squares of the line already blocked across it
+	Blocked     []int       // squares newly blocked along the line
+}
+
+// StaticExplanation records how static analysis decided whether a board is
+// impossible. Square is the first blocked square on the primary piece's path
+// to the exit, or -1 if there is none, which for an impossible board means
+// that the exit is not in line with the primary piece. Wall is set if Square
+// is a wall, which is blocked without any deductions.
+type StaticExplanation struct {
+	Impossible bool
+	Square     int
+	Wall       bool
+	Deductions []StaticDeduction
+	board      *Board
+}
+
+// record adds a deduction for the given line, whose squares in blocked were
+// given as blocked from the other direction.
+func (sa *staticScratch) record(board *Board, orientation Orientation, line int, blocked, cells []int) {
+	w := board.Width
+	d := StaticDeduction{Step: sa.round, Orientation: orientation, Line: line, Blocked: cells}
+	for i, piece := range board.Pieces {
+		if piece.Orientation != orientation {
+			continue
+		}
+		if orientation == Horizontal && piece.Row(w) == line || orientation == Vertical && piece.Col(w) == line {
+			d.Pieces = append(d.Pieces, i)
+		}
+	}
+	for _, b := range blocked {
+		if orientation == Horizontal {
+			d.Given = append(d.Given, line*w+b)
+		} else {
+			d.Given = append(d.Given, b*w+line)
+		}
+	}
+	sa.deductions = append(sa.deductions, d)
+}
+
+// Explain is like Impossible but records the deductions that lead to its
+// verdict.
+func (sa *StaticAnalyzer) Explain(board *Board) *StaticExplanation {
+	scratch := staticScratchPool.Get().(*staticScratch)
+	defer staticScratchPool.Put(scratch)
+	scratch.explain = true
+	scratch.deductions = nil
+	scratch.analyze(board)
+	e := &StaticExplanation{Square: -1, Deductions: scratch.deductions, board: board.Copy()}
+	scratch.explain = false
+	scratch.deductions = nil
+	if !board.inLane(0, board.Target()) {
+		e.Impossible = true
+		return e
+	}
+	idx, stride, n := board.piecePath(0, board.Target())
+	for i := 0; i < n; i++ {
+		if scratch.horz[idx] || scratch.vert[idx] {
+			e.Impossible = true
+			e.Square = idx
+			e.Wall = board.pieceAt(idx) < 0
+			break
+		}
+		idx += stride
+	}
+	return e
+}
+
+// Chain returns the deductions that blocked Square and, in turn, those that
+// blocked the squares they were given, in the order they were made. Walls
+// are blocked from the start. It returns nothing if the board is not
+// impossible.
+func (e *StaticExplanation) Chain() []StaticDeduction {
+	if !e.Impossible {
+		return nil
+	}
+	type blocker struct {
+		square      int
+		orientation Orientation
+	}
+	producer := make(map[blocker]int)
+	for i, d := range e.Deductions {
+		for _, square := range d.Blocked {
+			producer[blocker{square, d.Orientation}] = i
+		}
+	}
+	needed := make([]bool, len(e.Deductions))
+	var visit func(int)
+	visit = func(i int) {
+		if needed[i] {
+			return
+		}
+		needed[i] = true
+		// squares given to a row were blocked by columns and vice versa
+		across := Horizontal
+		if e.Deductions[i].Orientation == Horizontal {
+			across = Vertical
+		}
+		for _, square := range e.Deductions[i].Given {
+			if j, ok := producer[blocker{square, across}]; ok {
+				visit(j)
+			}
+		}
+	}
+	// one reason for the square is enough, so take the earlier one
+	h, hok := producer[blocker{e.Square, Horizontal}]
+	v, vok := producer[blocker{e.Square, Vertical}]
+	if hok && (!vok || h < v) {
+		visit(h)
+	} else if vok {
+		visit(v)
+	}
+	var chain []StaticDeduction
+	for i, d := range e.Deductions {
+		if needed[i] {
+			chain = append(chain, d)
+		}
+	}
+	return chain
+}
+
+// squareString returns a square as "(column, row)".
+func (e *StaticExplanation) squareString(square int) string {
+	w := e.board.Width
+	return fmt.Sprintf("(%d, %d)", square%w, square/w)
+}
+
+func (e *StaticExplanation) squaresString(squares []int) string {
+	result := make([]string, len(squares))
+	for i, square := range squares {
+		result[i] = e.squareString(square)
+	}
+	return strings.Join(result, " ")
+}
+
+// shown returns the chain of deductions, or every deduction if the board is
+// not impossible.
+func (e *StaticExplanation) shown() []StaticDeduction {
+	if e.Impossible {
+		return e.Chain()
+	}
+	return e.Deductions
+}
+
+// String describes the chain of deductions, one per line, or every
+// deduction if the board is not impossible.
+func (e *StaticExplanation) String() string {
+	var lines []string
+	if e.Impossible && e.Square < 0 {
+		lines = append(lines, "impossible: the exit is not in line with the primary piece")
+	} else if e.Wall {
+		lines = append(lines, fmt.Sprintf(
+			"impossible: square %s on the primary piece's path is a wall",
+			e.squareString(e.Square)))
+	} else if e.Impossible {
+		lines = append(lines, fmt.Sprintf(
+			"impossible: square %s on the primary piece's path is blocked",
+			e.squareString(e.Square)))
+	} else {
+		lines = append(lines, "not impossible: the primary piece's path is clear")
+	}
+	for _, d := range e.shown() {
+		line := "row"
+		if d.Orientation == Vertical {
+			line = "column"
+		}
+		labels := make([]string, len(d.Pieces))
+		for i, piece := range d.Pieces {
+			labels[i] = e.board.Label(piece)
+		}
+		s := fmt.Sprintf("step %d: %s %d with %s", d.Step, line, d.Line, strings.Join(labels, ", "))
+		if len(d.Given) > 0 {
+			s += fmt.Sprintf(" given %s", e.squaresString(d.Given))
+		}
+		s += fmt.Sprintf(" blocks %s", e.squaresString(d.Blocked))
+		lines = append(lines, s)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Render draws the board with the squares blocked by the deductions that
+// String lists shaded and numbered by step, and Square outlined.
+func (e *StaticExplanation) Render() image.Image {
+	return renderBoard(e.board, e)
+}
+
+// ExplainImpossible runs static analysis on the board, recording why it is
+// or is not impossible.
+func (board *Board) ExplainImpossible() *StaticExplanation {
+	return theStaticAnalyzer.Explain(board)
+}
@@ -0,0 +1,83 @@
+package rush
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestExplainImpossible(t *testing.T) {
+	board, err := NewBoardFromString("FF.BC....BC.AA.BC....DDDHHH...EEEGGG")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := board.ExplainImpossible()
+	if !e.Impossible || !board.Impossible() {
+		t.Fatal("expected impossible")
+	}
+	if e.Square/board.Width != board.Pieces[0].Row(board.Width) {
+		t.Fatalf("square %d is not on the primary piece's row", e.Square)
+	}
+	chain := e.Chain()
+	if len(chain) == 0 {
+		t.Fatal("expected a chain of deductions")
+	}
+	found := false
+	for _, square := range chain[len(chain)-1].Blocked {
+		found = found || square == e.Square
+	}
+	if !found {
+		t.Fatal("expected the last deduction to block the square")
+	}
+	if !strings.HasPrefix(e.String(), "impossible") || strings.Count(e.String(), "\n") != len(chain) {
+		t.Fatalf("unexpected explanation:\n%s", e)
+	}
+
+	// every blocked square is accounted for by a deduction
+	var squares []int
+	seen := make(map[int]bool)
+	for _, i := range board.Walls {
+		seen[i] = true
+	}
+	for _, d := range e.Deductions {
+		for _, i := range d.Blocked {
+			seen[i] = true
+		}
+	}
+	for i := range seen {
+		squares = append(squares, i)
+	}
+	sort.Ints(squares)
+	if !reflect.DeepEqual(squares, board.BlockedSquares()) {
+		t.Fatalf("got %v, want %v", squares, board.BlockedSquares())
+	}
+
+	board, err = NewBoardFromString("BB.C..D..C..DAAC..D.EE..F.....F.GGG.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e = board.ExplainImpossible()
+	if e.Impossible || e.Square != -1 || e.Chain() != nil {
+		t.Fatalf("expected not impossible, got:\n%s", e)
+	}
+
+	// a wall needs no deductions but is still named
+	board, err = NewBoardFromString("B.....B.....AA.x..CC....D.....D.....")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e = board.ExplainImpossible()
+	if !e.Impossible || !e.Wall || e.Square != 15 || len(e.Chain()) != 0 {
+		t.Fatalf("expected the wall at 15, got:\n%s", e)
+	}
+	if e.String() != "impossible: square (3, 2) on the primary piece's path is a wall" {
+		t.Fatalf("unexpected explanation:\n%s", e)
+	}
+	for i := 0; i < 100; i++ {
+		board := NewRandomBoard(6, 6, 2, 2, 10, 0)
+		if board.ExplainImpossible().Impossible != board.Impossible() {
+			t.Fatalf("explanation disagrees with Impossible for %s", board)
+		}
+	}
+}
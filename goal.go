@@ -0,0 +1,114 @@
+package rush
+
+// Goal decides when a board is solved. MinMoves must return a lower bound on
+// the number of moves needed to reach a solved state (zero if the board is
+// already solved). The bound must never overestimate or the solver will miss
+// optimal solutions.
+type Goal interface {
+	IsSolved(board *Board) bool
+	MinMoves(board *Board) int
+}
+
+// DefaultGoal returns the standard goal: the primary piece at its exit.
+func DefaultGoal(board *Board) Goal {
+	return PieceTarget{0, board.Target()}
+}
+
+// isDefaultGoal reports whether goal is the DefaultGoal of board.
+func isDefaultGoal(board *Board, goal Goal) bool {
+	t, ok := goal.(PieceTarget)
+	return ok && t == PieceTarget{0, board.Target()}
+}
+
+// PieceTarget is a Goal that is satisfied when the piece with the given
+// index sits at the given position.
+type PieceTarget struct {
+	Piece    int
+	Position int
+}
+
+func (t PieceTarget) IsSolved(board *Board) bool {
+	return board.Pieces[t.Piece].Position == t.Position
+}
+
+func (t PieceTarget) MinMoves(board *Board) int {
+	if t.IsSolved(board) {
+		return 0
+	}
+	if !board.inLane(t.Piece, t.Position) {
+		return unsolvableMoves
+	}
+	// the piece itself must move, as must every piece in its way, once each:
+	// a piece in the same lane covers several cells of the path but may
+	// clear them all in one move
+	var buf [16]int
+	blockers := buf[:0]
+	idx, stride, n := board.piecePath(t.Piece, t.Position)
+	for i := 0; i < n; i++ {
+		if board.cellOccupied(idx) {
+			j := board.pieceAt(idx)
+			if j < 0 {
+				// a wall is in the way
+				return unsolvableMoves
+			}
+			if len(blockers) == 0 || blockers[len(blockers)-1] != j {
+				blockers = append(blockers, j)
+			}
+		}
+		idx += stride
+	}
+	return 1 + len(blockers)
+}
+
+// PieceTargets is a Goal that is satisfied when every one of its targets is
+// satisfied at once.
+type PieceTargets []PieceTarget
+
+func (ts PieceTargets) IsSolved(board *Board) bool {
+	for _, t := range ts {
+		if !t.IsSolved(board) {
+			return false
+		}
+	}
+	return true
+}
+
+func (ts PieceTargets) MinMoves(board *Board) int {
+	// the best single-target bound applies, but so does the number of
+	// distinct pieces that are not yet in place, as each needs its own move
+	result := 0
+	pieces := 0
+	for i, t := range ts {
+		m := t.MinMoves(board)
+		if m == 0 {
+			continue
+		}
+		result = maxInt(result, m)
+		counted := false
+		for _, u := range ts[:i] {
+			if u.Piece == t.Piece && !u.IsSolved(board) {
+				counted = true
+				break
+			}
+		}
+		if !counted {
+			pieces++
+		}
+	}
+	return maxInt(result, pieces)
+}
+
+// GoalFunc adapts an arbitrary predicate to a Goal. Nothing is known about
+// the predicate, so the only lower bound is a single move.
+type GoalFunc func(board *Board) bool
+
+func (f GoalFunc) IsSolved(board *Board) bool {
+	return f(board)
+}
+
+func (f GoalFunc) MinMoves(board *Board) int {
+	if f(board) {
+		return 0
+	}
+	return 1
+}
@@ -0,0 +1,96 @@
+package rush
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// checkGoal compares the goal's lower bound and the solvers' results with
+// the distances found by breadth-first search, for every state reachable
+// from board.
+func checkGoal(t *testing.T, name string, board *Board, goal Goal) *Cluster {
+	cluster := NewClusterWithGoal(board, goal)
+	if !cluster.Solvable {
+		t.Fatalf("%s: expected a solvable cluster", name)
+	}
+	for b := range board.StateIterator() {
+		d, _ := cluster.Distance(b)
+		if d < 0 {
+			continue
+		}
+		if m := goal.MinMoves(b); m > d {
+			t.Fatalf("%s: lower bound %d exceeds %d moves for\n%s", name, m, d, b)
+		}
+		solution := NewSolverWithGoal(b, goal).Solve()
+		if solution.NumMoves != d {
+			t.Fatalf("%s: got %s, want %d moves for\n%s", name, solution, d, b)
+		}
+		if cost := NewCostSolverWithGoal(b, goal, MoveCost{}).Solve(); cost.NumMoves != d {
+			t.Fatalf("%s: cost solver got %s, want %d moves", name, cost, d)
+		}
+		if bidir := NewBidirectionalSolverWithGoal(b, goal).Solve(); bidir.NumMoves != d {
+			t.Fatalf("%s: bidirectional solver got %s, want %d moves", name, bidir, d)
+		}
+	}
+	_, solution := NewUnsolverWithGoal(board, goal).Unsolve()
+	if solution.NumMoves != cluster.NumMoves() {
+		t.Fatalf("%s: unsolve got %d moves, want %d", name, solution.NumMoves, cluster.NumMoves())
+	}
+	return cluster
+}
+
+func TestGoals(t *testing.T) {
+	// C and D are trucks in column 0; C must reach row 2, which only takes
+	// D moving up and C following it
+	board, err := NewBoardFromString("..../..../D.../D.AA/C.../C...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, d := 1, 2
+	if board.Label(c) != "C" || board.Label(d) != "D" {
+		t.Fatalf("unexpected labels %v", board.Labels)
+	}
+	single := PieceTarget{c, 2 * board.Width}
+	if m := single.MinMoves(board); m != 2 {
+		t.Fatalf("got lower bound %d, want 2", m)
+	}
+	checkGoal(t, "same lane", board, single)
+
+	board, err = NewBoardFromString("BB.C..D..C..DAAC..D.EE..F.....F.GGG.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGoal(t, "default", board, DefaultGoal(board))
+	checkGoal(t, "single", board, PieceTarget{4, 19})
+	multi := PieceTargets{{0, board.Target()}, {4, 19}, {1, 1}}
+	checkGoal(t, "multi", board, multi)
+	predicate := GoalFunc(func(b *Board) bool {
+		return b.Pieces[0].Col(b.Width) == 3 && b.Pieces[1].Col(b.Width) == 4
+	})
+	cluster := checkGoal(t, "predicate", board, predicate)
+
+	// the graph's hardest states are those of the cluster
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan []byte)
+	go func() {
+		data, _ := ioutil.ReadAll(r)
+		done <- data
+	}()
+	GraphWithGoal(board, predicate)
+	os.Stdout = stdout
+	w.Close()
+	output := string(<-done)
+	if n := strings.Count(output, `fillcolor = "#E94128"`); n != cluster.Distances[cluster.NumMoves()] {
+		t.Fatalf("graph has %d hardest states, want %d", n, cluster.Distances[cluster.NumMoves()])
+	}
+	if n := strings.Count(output, `fillcolor = "#3F628F"`); n != cluster.NumMoves()+1 {
+		t.Fatalf("graph solution has %d states, want %d", n, cluster.NumMoves()+1)
+	}
+}
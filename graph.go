@@ -17,6 +17,12 @@ func MakeLink(id1, id2 int) Link {
 }
 
 func Graph(input *Board) {
+	GraphWithGoal(input, DefaultGoal(input))
+}
+
+// GraphWithGoal is like Graph but measures distances to states satisfying
+// goal.
+func GraphWithGoal(input *Board, goal Goal) {
 	ids := make(map[MemoKey]int)
 	numMovesToIDs := make(map[int][]int)
 	idToNumMoves := make(map[int]int)
@@ -31,6 +37,7 @@ func Graph(input *Board) {
 	fmt.Println("pad=1;")
 	var maxMoves int
 	solver := NewSolverWithStaticAnalyzer(input, nil)
+	solver.goal = goal
 	for len(q) > 0 {
 		board := q[len(q)-1]
 		key := board.MemoKey()
@@ -41,7 +48,7 @@ func Graph(input *Board) {
 		id := idCounter
 		idCounter++
 		ids[*key] = id
-		solver.board = board
+		solver.retarget(board)
 		numMoves := solver.UnsafeSolve().NumMoves
 		if numMoves > maxMoves {
 			maxMoves = numMoves
@@ -56,7 +63,7 @@ func Graph(input *Board) {
 		}
 	}
 
-	board, solution := input.Unsolve()
+	board, solution := NewUnsolverWithGoal(input, goal).Unsolve()
 	for _, move := range solution.Moves {
 		solutionIDs = append(solutionIDs, ids[*board.MemoKey()])
 		board.DoMove(move)
@@ -0,0 +1,173 @@
+package rush
+
+import "math"
+
+/*
+
+A Heuristic gives the solver a lower bound on the number of moves still
+needed to solve a board, letting it prune states that cannot be solved
+within the current depth limit. With a heuristic the iterative deepening
+search in Solver is IDA*. A heuristic must never overestimate, or the solver
+will miss optimal solutions, and the ones below assume the default goal of
+the primary piece reaching its exit.
+
+The goal's own MinMoves always applies: one move for the primary piece plus
+one for each piece in its way. The heuristics here tighten that bound.
+
+*/
+
+type Heuristic interface {
+	MinMoves(board *Board) int
+}
+
+// unsolvableMoves is returned by heuristics for boards that can never be
+// solved. It is larger than any depth the solver will reach.
+const unsolvableMoves = math.MaxInt32
+
+// BlockerHeuristic extends the blocker count with the blockers of blockers.
+// A piece in the primary piece's way must move off its lane to one side or
+// the other, and any piece in the cells it sweeps on the way must move too.
+// If every side a blocker could use is obstructed by pieces that are not
+// already counted, one of those pieces must also move. Blockers whose sets
+// of obstructing pieces are disjoint each add a move of their own.
+type BlockerHeuristic struct{}
+
+func (BlockerHeuristic) MinMoves(board *Board) int {
+	target := board.Target()
+	if board.Pieces[0].Position == target {
+		return 0
+	}
+	if !board.inLane(0, target) {
+		return unsolvableMoves
+	}
+	w := board.Width
+	primary := board.Pieces[0]
+	lane := primary.Row(w)
+	if primary.Orientation == Vertical {
+		lane = primary.Col(w)
+	}
+
+	// find the pieces in the way
+	var buf [3][16]int
+	blockers := buf[0][:0]
+	idx, stride, n := board.piecePath(0, target)
+	for i := 0; i < n; i++ {
+		if board.cellOccupied(idx) {
+			j := board.pieceAt(idx)
+			if j < 0 {
+				// a wall is in the way
+				return unsolvableMoves
+			}
+			blockers = append(blockers, j)
+		}
+		idx += stride
+	}
+	result := 1 + len(blockers)
+
+	// for each blocker, gather the uncounted pieces that obstruct every side
+	// it could move to
+	used := buf[1][:0]
+	for _, b := range blockers {
+		piece := board.Pieces[b]
+		offset, size := piece.Row(w), board.Height
+		if piece.Orientation == Horizontal {
+			offset, size = piece.Col(w), board.Width
+		}
+		stride := piece.Stride(w)
+		obstructions := buf[2][:0]
+		free := false
+		feasible := false
+		for _, end := range []int{lane - piece.Size, lane + 1} {
+			if end < 0 || end+piece.Size > size {
+				continue
+			}
+			// cells swept moving from offset to end
+			first, last := end, offset-1
+			if end > offset {
+				first, last = offset+piece.Size, end+piece.Size-1
+			}
+			side := len(obstructions)
+			wall := false
+			for o := first; o <= last; o++ {
+				i := piece.Position + (o-offset)*stride
+				if !board.cellOccupied(i) {
+					continue
+				}
+				j := board.pieceAt(i)
+				if j < 0 {
+					wall = true
+					break
+				}
+				if j != 0 && !containsInt(blockers, j) {
+					obstructions = append(obstructions, j)
+				}
+			}
+			if wall {
+				obstructions = obstructions[:side]
+				continue
+			}
+			feasible = true
+			if len(obstructions) == side {
+				free = true
+				break
+			}
+		}
+		if !feasible {
+			return unsolvableMoves
+		}
+		if free {
+			continue
+		}
+		// only count blockers whose obstructions are disjoint from those
+		// already counted
+		disjoint := true
+		for _, j := range obstructions {
+			if containsInt(used, j) {
+				disjoint = false
+				break
+			}
+		}
+		if disjoint {
+			used = append(used, obstructions...)
+			result++
+		}
+	}
+	return result
+}
+
+func containsInt(a []int, x int) bool {
+	for _, y := range a {
+		if x == y {
+			return true
+		}
+	}
+	return false
+}
+
+// pieceAt returns the index of the piece covering cell i, or -1 if there is
+// none.
+func (board *Board) pieceAt(i int) int {
+	w := board.Width
+	for j, piece := range board.Pieces {
+		stride := piece.Stride(w)
+		p := piece.Position
+		if i >= p && i <= p+(piece.Size-1)*stride && (i-p)%stride == 0 {
+			return j
+		}
+	}
+	return -1
+}
+
+// StaticHeuristic prunes boards that static analysis proves impossible and
+// otherwise falls back to BlockerHeuristic. The analysis is costly, so it
+// pays off mostly on boards with many dead ends.
+type StaticHeuristic struct {
+	SA *StaticAnalyzer
+}
+
+func (h StaticHeuristic) MinMoves(board *Board) int {
+	if h.SA.Impossible(board) {
+		return unsolvableMoves
+	}
+	return BlockerHeuristic{}.MinMoves(board)
+}
@@ -0,0 +1,32 @@
+package rush
+
+import "testing"
+
+func TestHeuristics(t *testing.T) {
+	board, err := NewBoardFromString("BB.C..D..C..DAAC..D.EE..F.....F.GGG.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	heuristics := []Heuristic{BlockerHeuristic{}, StaticHeuristic{NewStaticAnalyzer()}}
+	expected := board.Solve()
+	for _, h := range heuristics {
+		solution := NewSolverWithHeuristic(board, h).Solve()
+		if solution.NumMoves != expected.NumMoves {
+			t.Fatalf("got %d moves, want %d", solution.NumMoves, expected.NumMoves)
+		}
+		if solution.Nodes > expected.Nodes {
+			t.Fatalf("got %d nodes, want at most %d", solution.Nodes, expected.Nodes)
+		}
+	}
+
+	// the bounds must never overestimate
+	for b := range board.StateIterator() {
+		solution := b.Solve()
+		for _, h := range heuristics {
+			m := h.MinMoves(b)
+			if solution.Solvable && m > solution.NumMoves {
+				t.Fatalf("bound %d exceeds %d moves for %s", m, solution.NumMoves, b)
+			}
+		}
+	}
+}
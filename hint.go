@@ -0,0 +1,172 @@
+package rush
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+/*
+
+A DistanceTable holds the number of moves needed to solve every state in a
+cluster, the set of states reachable from one another. It is built by
+searching the board's Cluster, which costs about as much as one hard solve,
+after which the optimal moves from any state of the cluster can be looked up
+without searching. This makes it suitable for hints in an interactive
+player, where the same cluster is asked about again after every move.
+
+Board.Hint keeps the tables of the clusters it was most recently asked
+about, so a player only pays for the search on its first hint.
+
+*/
+
+type DistanceTable struct {
+	cluster *Cluster
+}
+
+func NewDistanceTable(board *Board) *DistanceTable {
+	return NewDistanceTableWithGoal(board, DefaultGoal(board))
+}
+
+// NewDistanceTableWithGoal returns the distances to states satisfying goal
+// of every state reachable from board.
+func NewDistanceTableWithGoal(board *Board, goal Goal) *DistanceTable {
+	cluster, _ := buildCluster(board, goal, false, nil)
+	return &DistanceTable{cluster}
+}
+
+// Size returns the number of states in the table.
+func (t *DistanceTable) Size() int {
+	return t.cluster.NumStates
+}
+
+// Distance returns the number of moves needed to solve board, or -1 if it
+// cannot be solved. The second result is false if board is not part of the
+// table's cluster.
+func (t *DistanceTable) Distance(board *Board) (int, bool) {
+	return t.cluster.Distance(board)
+}
+
+// Hint returns the moves that start an optimal solution of board, which
+// must be part of the table's cluster.
+func (t *DistanceTable) Hint(board *Board) (Hint, error) {
+	d, ok := t.Distance(board)
+	if !ok {
+		return Hint{}, fmt.Errorf("board is not part of the table's cluster")
+	}
+	hint := Hint{Solvable: d >= 0, Distance: d, Labels: board.Labels}
+	if d <= 0 {
+		if !hint.Solvable {
+			hint.Distance = 0
+		}
+		return hint, nil
+	}
+	board = board.Copy()
+	for _, move := range board.Moves(nil) {
+		board.DoMove(move)
+		if d1, _ := t.Distance(board); d1 == d-1 {
+			hint.Moves = append(hint.Moves, move)
+		}
+		board.UndoMove(move)
+	}
+	return hint, nil
+}
+
+// sameLayout reports whether a and b have the same size, exit, walls and
+// pieces, so that their memo keys are comparable.
+func sameLayout(a, b *Board) bool {
+	if a.Width != b.Width || a.Height != b.Height || a.Exit != b.Exit {
+		return false
+	}
+	if len(a.Pieces) != len(b.Pieces) || len(a.Walls) != len(b.Walls) {
+		return false
+	}
+	for i, p := range a.Pieces {
+		q := b.Pieces[i]
+		if p.Size != q.Size || p.Orientation != q.Orientation {
+			return false
+		}
+	}
+	if len(a.Pieces) > 0 && a.exitLane() != b.exitLane() {
+		return false
+	}
+	for i, w := range a.Walls {
+		if w != b.Walls[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Hint is the advice for a single position: every move that starts an
+// optimal solution and the number of moves that solution takes. Labels
+// holds the board's piece labels, as in Solution.
+type Hint struct {
+	Solvable bool
+	Distance int
+	Moves    []Move
+	Labels   []string
+}
+
+// MoveStrings returns the moves in "A+1" notation using the hint's piece
+// labels.
+func (hint Hint) MoveStrings() []string {
+	board := Board{Labels: hint.Labels}
+	moves := make([]string, len(hint.Moves))
+	for i, move := range hint.Moves {
+		moves[i] = board.MoveString(move)
+	}
+	return moves
+}
+
+func (hint Hint) String() string {
+	if !hint.Solvable {
+		return "unsolvable"
+	}
+	if hint.Distance == 0 {
+		return "solved"
+	}
+	return fmt.Sprintf("%d moves left: %s",
+		hint.Distance, strings.Join(hint.MoveStrings(), " "))
+}
+
+// hintCacheSize is the number of distance tables kept by Board.Hint.
+const hintCacheSize = 8
+
+var hintCache struct {
+	sync.Mutex
+	tables []*DistanceTable // most recently used first
+}
+
+// Hint returns the optimal next moves from the board and how many moves
+// remain. The first hint for a cluster searches all of it, and later hints
+// for the same cluster are looked up.
+func (board *Board) Hint() Hint {
+	if err := board.Validate(); err != nil {
+		return Hint{}
+	}
+	hintCache.Lock()
+	var table *DistanceTable
+	for i, t := range hintCache.tables {
+		if _, ok := t.Distance(board); ok {
+			table = t
+			copy(hintCache.tables[1:i+1], hintCache.tables[:i])
+			hintCache.tables[0] = t
+			break
+		}
+	}
+	hintCache.Unlock()
+
+	if table == nil {
+		table = NewDistanceTable(board)
+		hintCache.Lock()
+		tables := append([]*DistanceTable{table}, hintCache.tables...)
+		if len(tables) > hintCacheSize {
+			tables = tables[:hintCacheSize]
+		}
+		hintCache.tables = tables
+		hintCache.Unlock()
+	}
+	hint, _ := table.Hint(board)
+	return hint
+}
@@ -0,0 +1,58 @@
+package rush
+
+import "testing"
+
+func TestHint(t *testing.T) {
+	board, err := NewBoardFromString("BB.C..D..C..DAAC..D.EE..F.....F.GGG.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := NewDistanceTable(board)
+	if table.Size() != board.ReachableStates() {
+		t.Fatalf("got %d states, want %d", table.Size(), board.ReachableStates())
+	}
+	board.Hint()
+	cached := len(hintCache.tables)
+	n := 0
+	for b := range board.StateIterator() {
+		n++
+		if n%10 != 0 {
+			continue
+		}
+		solution := b.Solve()
+		hint, err := table.Hint(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hint.Solvable != solution.Solvable || hint.Distance != solution.NumMoves {
+			t.Fatalf("got %s, want %s", hint, solution)
+		}
+		if hint.Distance > 0 && len(hint.Moves) == 0 {
+			t.Fatal("expected hint moves")
+		}
+		for _, move := range hint.Moves {
+			c := b.Copy()
+			c.DoMove(move)
+			if d, _ := table.Distance(c); d != hint.Distance-1 {
+				t.Fatalf("move %s leads to distance %d", move, d)
+			}
+		}
+		if b.Hint().String() != hint.String() {
+			t.Fatalf("got %s, want %s", b.Hint(), hint)
+		}
+	}
+	if len(hintCache.tables) != cached {
+		t.Fatal("expected later hints to use the cached table")
+	}
+
+	other, err := NewBoardFromString("..BBBD..E..DAAE..D..EHHHCC.F...GGF..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := table.Hint(other); err == nil {
+		t.Fatal("expected error for a board from another cluster")
+	}
+	if hint := other.Hint(); hint.Solvable || len(hint.Moves) != 0 {
+		t.Fatalf("expected unsolvable, got %s", hint)
+	}
+}
@@ -1,55 +1,349 @@
 package rush
 
-type MemoKey [MaxPieces]int
+import "math/bits"
 
-func MakeMemoKey(pieces []Piece) MemoKey {
-	var key MemoKey
-	for i, piece := range pieces {
-		key[i] = piece.Position
-	}
-	return key
+/*
+
+A MemoKey packs the state of a board into as few 64-bit words as it needs.
+Pieces never leave their lane, so each piece is stored as its offset along
+the lane (its column if it is horizontal, its row if it is vertical) using
+just enough bits for the largest possible offset. Fields are packed from the
+most significant bit of the first word down, piece 0 first, and never
+straddle two words. The primary piece always gets the top byte so that it
+can be masked off.
+
+Within a lane the offset grows with the position, so comparing two keys
+word by word orders them the same way as comparing piece positions one at a
+time.
+
+Moving a piece adds steps << shift to the word holding its field, so the key
+is kept up to date without being rebuilt. Typical boards fit in the two words
+held inline. Boards with many pieces on long lanes spill into further words,
+which are carried in ext as a big-endian string so that MemoKey stays
+comparable.
+
+*/
+
+type MemoKey struct {
+	words [2]uint64
+	ext   string
 }
 
+const primaryKeyBits = 8
+
 func (a *MemoKey) Less(b *MemoKey, primary bool) bool {
-	var i int
+	a0, b0 := a.words[0], b.words[0]
 	if !primary {
-		i++
+		a0 <<= primaryKeyBits
+		b0 <<= primaryKeyBits
+	}
+	if a0 != b0 {
+		return a0 < b0
+	}
+	if a.words[1] != b.words[1] {
+		return a.words[1] < b.words[1]
+	}
+	return a.ext < b.ext
+}
+
+// appendWords appends the words of the key to buf.
+func (key *MemoKey) appendWords(buf []uint64) []uint64 {
+	buf = append(buf, key.words[0], key.words[1])
+	for i := 0; i+8 <= len(key.ext); i += 8 {
+		var x uint64
+		for j := 0; j < 8; j++ {
+			x = x<<8 | uint64(key.ext[i+j])
+		}
+		buf = append(buf, x)
+	}
+	return buf
+}
+
+func makeKeyExt(words []uint64) string {
+	buf := make([]byte, len(words)*8)
+	for i, x := range words {
+		for j := 7; j >= 0; j-- {
+			buf[i*8+j] = byte(x)
+			x >>= 8
+		}
+	}
+	return string(buf)
+}
+
+// keyExtEqual reports whether ext is the string makeKeyExt would build from
+// words, without building it.
+func keyExtEqual(ext string, words []uint64) bool {
+	if len(ext) != len(words)*8 {
+		return false
 	}
-	for ; i < MaxPieces; i++ {
-		if a[i] != b[i] {
-			return a[i] < b[i]
+	for i, x := range words {
+		for j := 7; j >= 0; j-- {
+			if ext[i*8+j] != byte(x) {
+				return false
+			}
+			x >>= 8
 		}
 	}
-	return false
+	return true
+}
+
+// keyField returns the offset of a piece along its lane and the number of
+// bits needed to store the largest possible offset.
+func keyField(w, h int, piece Piece) (offset, n int) {
+	if piece.Orientation == Vertical {
+		offset = piece.Position / w
+		n = bits.Len(uint(h - piece.Size))
+	} else {
+		offset = piece.Position % w
+		n = bits.Len(uint(w - piece.Size))
+	}
+	if n == 0 {
+		n = 1
+	}
+	return
+}
+
+// appendKeyShift appends the field of the next piece to a key layout. Each
+// entry is word<<6 | shift.
+func appendKeyShift(w, h int, pieces []Piece, shifts []uint16) []uint16 {
+	i := len(shifts)
+	_, n := keyField(w, h, pieces[i])
+	start := 0
+	if i == 0 {
+		n = primaryKeyBits
+	} else {
+		// start just below the previous field, counting bits from the top
+		// of the first word
+		prev := int(shifts[i-1])
+		start = (prev>>6)*64 + 64 - prev&63
+		if start%64+n > 64 {
+			start += 64 - start%64
+		}
+	}
+	word := start / 64
+	shift := 64 - start%64 - n
+	return append(shifts, uint16(word<<6|shift))
+}
+
+// addKeyShift lays out the field of the last piece, growing the key if the
+// field starts a new word.
+func (board *Board) addKeyShift() {
+	board.keyShifts = appendKeyShift(board.Width, board.Height, board.Pieces, board.keyShifts)
+	if word := int(board.keyShifts[len(board.keyShifts)-1] >> 6); word >= 2+len(board.keyExt) {
+		board.keyExt = append(board.keyExt, 0)
+	}
+}
+
+// removeKeyShift removes the field of the last piece, shrinking the key if
+// the field was alone in its word.
+func (board *Board) removeKeyShift() {
+	n := len(board.keyShifts) - 1
+	board.addKeyField(n, -1)
+	board.keyShifts = board.keyShifts[:n]
+	words := 0
+	if n > 0 {
+		words = int(board.keyShifts[n-1]>>6) + 1
+	}
+	if len(board.keyExt) > words-2 {
+		board.keyExt = board.keyExt[:maxInt(words-2, 0)]
+	}
+}
+
+// layoutMemoKey rebuilds the key layout and the key itself from scratch.
+func (board *Board) layoutMemoKey() {
+	board.keyShifts = board.keyShifts[:0]
+	board.keyExt = board.keyExt[:0]
+	board.memoKey = MemoKey{}
+	for i := range board.Pieces {
+		board.addKeyShift()
+		board.addKeyField(i, 1)
+	}
+}
+
+// addKeyField adds (sign = 1) or removes (sign = -1) the field of the i-th
+// piece to or from the key.
+func (board *Board) addKeyField(i, sign int) {
+	offset, _ := keyField(board.Width, board.Height, board.Pieces[i])
+	board.moveKeyField(i, sign*offset)
 }
 
+// moveKeyField updates the key when the i-th piece moves.
+func (board *Board) moveKeyField(i, steps int) {
+	s := board.keyShifts[i]
+	d := uint64(int64(steps) << (s & 63))
+	if word := s >> 6; word < 2 {
+		board.memoKey.words[word] += d
+	} else {
+		board.keyExt[word-2] += d
+	}
+}
+
+/*
+
+Memo is an open-addressing hash table from MemoKey to depth, using linear
+probing. Keys are stored as flat runs of words and depths in a parallel
+slice, so the table is far smaller and faster than a map. All keys in a
+memo must come from the same board, so they all have the same number of
+words. A depth of zero in the table marks an empty slot, so depths are
+stored plus one.
+
+*/
+
+const memoInitialSize = 1 << 10
+
 type Memo struct {
-	data map[MemoKey]int
-	hits uint64
+	width  int // words per key, set by the first key
+	keys   []uint64
+	depths []int32
+	size   int
+	hits   uint64
+	misses uint64
+	buf    []uint64
 }
 
 func NewMemo() *Memo {
-	data := make(map[MemoKey]int)
-	return &Memo{data, 0}
+	return &Memo{}
 }
 
 func (memo *Memo) Size() int {
-	return len(memo.data)
+	return memo.size
 }
 
 func (memo *Memo) Hits() uint64 {
 	return memo.hits
 }
 
+// Misses returns how many calls to Add found no entry for their key.
+func (memo *Memo) Misses() uint64 {
+	return memo.misses
+}
+
+// Evictions always returns zero, since a Memo grows to hold every key.
+func (memo *Memo) Evictions() uint64 {
+	return 0
+}
+
+func hashWords(words []uint64) uint64 {
+	var h uint64
+	for _, x := range words {
+		h = (h ^ x) * 0x9e3779b97f4a7c15
+		h ^= h >> 32
+	}
+	h ^= h >> 29
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 32
+	return h
+}
+
+// words unpacks key into the memo's scratch buffer, allocating the table on
+// first use. An empty table may change to keys of a different size.
+func (memo *Memo) words(key *MemoKey) []uint64 {
+	memo.buf = key.appendWords(memo.buf[:0])
+	if len(memo.buf) != memo.width {
+		if memo.size != 0 {
+			panic("rush: memo keys have different sizes")
+		}
+		memo.width = len(memo.buf)
+		if memo.depths == nil {
+			memo.depths = make([]int32, memoInitialSize)
+		}
+		memo.keys = make([]uint64, len(memo.depths)*memo.width)
+	}
+	return memo.buf
+}
+
+// Clear removes every entry and resets the counters, keeping the table's
+// storage for reuse.
+func (memo *Memo) Clear() {
+	for i := range memo.depths {
+		memo.depths[i] = 0
+	}
+	memo.size = 0
+	memo.hits = 0
+	memo.misses = 0
+}
+
+// slot returns the index of key in the table, or of the empty slot where
+// it belongs.
+func (memo *Memo) slot(key []uint64) int {
+	mask := len(memo.depths) - 1
+	i := int(hashWords(key)) & mask
+	for memo.depths[i] != 0 && !memo.equal(i, key) {
+		i = (i + 1) & mask
+	}
+	return i
+}
+
+func (memo *Memo) equal(i int, key []uint64) bool {
+	// two-word keys are by far the most common
+	if memo.width == 2 {
+		return memo.keys[i*2] == key[0] && memo.keys[i*2+1] == key[1]
+	}
+	keys := memo.keys[i*memo.width:]
+	for j, x := range key {
+		if keys[j] != x {
+			return false
+		}
+	}
+	return true
+}
+
+func (memo *Memo) grow() {
+	w := memo.width
+	keys := memo.keys
+	depths := memo.depths
+	memo.keys = make([]uint64, len(keys)*2)
+	memo.depths = make([]int32, len(depths)*2)
+	for i, d := range depths {
+		if d != 0 {
+			key := keys[i*w : i*w+w]
+			j := memo.slot(key)
+			copy(memo.keys[j*w:], key)
+			memo.depths[j] = d
+		}
+	}
+}
+
 func (memo *Memo) Add(key *MemoKey, depth int) bool {
 	memo.hits++
-	if before, ok := memo.data[*key]; ok && before >= depth {
+	words := memo.words(key)
+	i := memo.slot(words)
+	before := memo.depths[i]
+	if before == 0 {
+		memo.misses++
+	} else if int(before)-1 >= depth {
 		return false
 	}
-	memo.data[*key] = depth
+	memo.put(i, words, depth)
 	return true
 }
 
 func (memo *Memo) Set(key *MemoKey, depth int) {
-	memo.data[*key] = depth
+	words := memo.words(key)
+	memo.put(memo.slot(words), words, depth)
+}
+
+func (memo *Memo) put(i int, key []uint64, depth int) {
+	if memo.depths[i] == 0 {
+		// keep the load factor at or below 3/4
+		if (memo.size+1)*4 > len(memo.depths)*3 {
+			memo.grow()
+			i = memo.slot(key)
+		}
+		memo.size++
+		copy(memo.keys[i*memo.width:], key)
+	}
+	memo.depths[i] = int32(depth) + 1
+}
+
+// memoTable is implemented by Memo, BoundedMemo and sharedMemo, which may
+// be used by several goroutines at once.
+type memoTable interface {
+	Add(key *MemoKey, depth int) bool
+	Set(key *MemoKey, depth int)
+	Size() int
+	Hits() uint64
+	Misses() uint64
+	Evictions() uint64
+	Clear()
 }
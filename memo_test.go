@@ -0,0 +1,208 @@
+package rush
+
+import "testing"
+
+func lessPositions(a, b *Board, primary bool) bool {
+	i := 0
+	if !primary {
+		i++
+	}
+	for ; i < len(a.Pieces); i++ {
+		p, q := a.Pieces[i].Position, b.Pieces[i].Position
+		if p != q {
+			return p < q
+		}
+	}
+	return false
+}
+
+func TestMemoKey(t *testing.T) {
+	board, err := NewBoardFromString("BBBCDEFGGCDEF.AADEHHI....JI.KK.JLLMM")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[MemoKey]string)
+	var states []*Board
+	for b := range board.StateIterator() {
+		// the incrementally updated key must match a rebuilt one
+		key := *b.MemoKey()
+		b.layoutMemoKey()
+		if key != *b.MemoKey() {
+			t.Fatalf("stale key for %s", b)
+		}
+		if other, ok := seen[key]; ok {
+			t.Fatalf("key collision: %s %s", b, other)
+		}
+		seen[key] = b.String()
+		states = append(states, b)
+	}
+	if len(states) != board.ReachableStates() {
+		t.Fatalf("got %d states, want %d", len(states), board.ReachableStates())
+	}
+	for i, a := range states {
+		b := states[(i*7+3)%len(states)]
+		for _, primary := range []bool{true, false} {
+			if a.MemoKey().Less(b.MemoKey(), primary) != lessPositions(a, b, primary) {
+				t.Fatalf("Less(%v) disagrees:\n%s\n%s", primary, a, b)
+			}
+		}
+	}
+
+	memo := NewMemo()
+	for _, b := range states {
+		if !memo.Add(b.MemoKey(), 1) {
+			t.Fatalf("duplicate key for %s", b)
+		}
+	}
+	if memo.Size() != len(states) {
+		t.Fatalf("got size %d, want %d", memo.Size(), len(states))
+	}
+	if memo.Add(states[0].MemoKey(), 1) || !memo.Add(states[0].MemoKey(), 2) {
+		t.Fatal("Add should only accept a greater depth")
+	}
+}
+
+func TestLargeBoard(t *testing.T) {
+	// 49 pieces need more than the two inline words of a MemoKey
+	board := NewEmptyBoard(16, 16)
+	board.AddPiece(Piece{7 * 16, 2, Horizontal})
+	for x := 0; x < 16; x++ {
+		for _, y := range []int{0, 10, 13} {
+			board.AddPiece(Piece{y*16 + x, 2, Vertical})
+		}
+	}
+	if err := board.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if board.MemoKey().ext == "" {
+		t.Fatal("expected key to spill past two words")
+	}
+	key := *board.MemoKey()
+	original := board.Copy()
+	for _, move := range board.Moves(nil) {
+		board.DoMove(move)
+		got := *board.MemoKey()
+		board.layoutMemoKey()
+		if got != *board.MemoKey() {
+			t.Fatalf("stale key after %s", move)
+		}
+		if key.Less(&got, true) != lessPositions(original, board, true) {
+			t.Fatalf("Less disagrees after %s", move)
+		}
+		board.UndoMove(move)
+	}
+	if *board.MemoKey() != key {
+		t.Fatal("key changed after undoing moves")
+	}
+	solution := board.Solve()
+	if !solution.Solvable || solution.NumMoves != 1 {
+		t.Fatalf("unexpected solution %s", solution)
+	}
+
+	// reading an unchanged key does not rebuild it
+	if n := testing.AllocsPerRun(100, func() { board.MemoKey() }); n != 0 {
+		t.Fatalf("got %g allocations per MemoKey", n)
+	}
+
+	// removing pieces shrinks the key back to two words
+	for len(board.Pieces) > 1 {
+		board.RemovePiece(len(board.Pieces) - 1)
+		got := *board.MemoKey()
+		board.layoutMemoKey()
+		if got != *board.MemoKey() {
+			t.Fatalf("stale key with %d pieces", len(board.Pieces))
+		}
+	}
+	if board.MemoKey().ext != "" {
+		t.Fatal("expected key to fit in two words")
+	}
+}
+
+func TestBoundedMemo(t *testing.T) {
+	board, err := NewBoardFromString("BBBCDEFGGCDEF.AADEHHI....JI.KK.JLLMM")
+	if err != nil {
+		t.Fatal(err)
+	}
+	small, err := NewBoardFromString("BB.C..D..C..DAAC..D.EE..F.....F.GGG.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := small.Solve()
+	for _, policy := range []ReplacementPolicy{DepthPreferred, AlwaysReplace} {
+		memo := NewBoundedMemo(1<<12, policy)
+		n := 0
+		for b := range board.StateIterator() {
+			memo.Add(b.MemoKey(), n%7)
+			n++
+		}
+		if memo.Capacity() != 128 || memo.Size() != memo.Capacity() {
+			t.Fatalf("got size %d and capacity %d", memo.Size(), memo.Capacity())
+		}
+		if memo.Misses() != uint64(n) || memo.Evictions() != uint64(n-memo.Size()) {
+			t.Fatalf("got %d misses and %d evictions", memo.Misses(), memo.Evictions())
+		}
+
+		// evictions cost time but never correctness
+		solution := NewSolverWithBoundedMemo(small, 1<<12, policy).Solve()
+		if !solution.Solvable || solution.NumMoves != expected.NumMoves {
+			t.Fatalf("unexpected %s", solution)
+		}
+		if solution.MemoEvictions == 0 || solution.MemoSize > 128 {
+			t.Fatalf("got %d evictions with size %d", solution.MemoEvictions, solution.MemoSize)
+		}
+	}
+
+	// a full bucket keeps its deeper entries over a shallower new one
+	memo := NewBoundedMemo(0, DepthPreferred)
+	var keys []MemoKey
+	for b := range board.StateIterator() {
+		keys = append(keys, *b.MemoKey())
+		if len(keys) == 5 {
+			break
+		}
+	}
+	for i := range keys[:4] {
+		memo.Add(&keys[i], 5)
+	}
+	memo.Add(&keys[4], 1)
+	if memo.Size() != 4 || memo.Evictions() != 1 {
+		t.Fatalf("got size %d and %d evictions", memo.Size(), memo.Evictions())
+	}
+	for i := range keys[:4] {
+		if memo.Add(&keys[i], 5) {
+			t.Fatalf("deeper entry %d was evicted", i)
+		}
+	}
+	if !memo.Add(&keys[4], 1) {
+		t.Fatal("shallower entry was stored")
+	}
+}
+
+func TestBoundedMemoUnsolvable(t *testing.T) {
+	// static analysis does not rule this board out, and it has far more
+	// states than the memo can hold
+	board, err := NewBoardFromString("..BB..GGC...AACHD.F.CHD.F...D..EEE..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if board.Impossible() || NewCluster(board).Solvable {
+		t.Fatal("expected an unsolvable board that is not impossible")
+	}
+	for _, policy := range []ReplacementPolicy{DepthPreferred, AlwaysReplace} {
+		solver := NewSolverWithBoundedMemo(board, 1<<10, policy)
+		solution := solver.Solve()
+		if solution.Solvable || solution.Aborted != nil && solution.Aborted != ErrNodeLimit {
+			t.Fatalf("got %s", solution)
+		}
+		if solution.MemoEvictions == 0 {
+			t.Fatal("expected the memo to overflow")
+		}
+
+		unsolver := NewUnsolverWithBoundedMemo(board, 1<<10, policy)
+		unsolver.SetLimits(Limits{MaxMemoSize: 1 << 20})
+		hardest, solution := unsolver.Unsolve()
+		if solution.Solvable || hardest.String() != board.String() {
+			t.Fatalf("got %s", solution)
+		}
+	}
+}
@@ -1,11 +1,13 @@
 package rush
 
 import (
+	"context"
 	"fmt"
 	"image"
 	"math"
 	"math/rand"
 	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -18,6 +20,16 @@ const (
 	Vertical
 )
 
+func (orientation Orientation) String() string {
+	switch orientation {
+	case Horizontal:
+		return "horizontal"
+	case Vertical:
+		return "vertical"
+	}
+	return fmt.Sprintf("Orientation(%d)", int(orientation))
+}
+
 // Piece represents a piece (a car or a truck) on the grid. Its position is
 // a zero-indexed int, 0 <= Position < W*H. Its size specifies how many cells
 // it occupies. Its orientation specifies whether it is vertical or horizontal.
@@ -42,6 +54,48 @@ func (piece *Piece) Col(w int) int {
 	return piece.Position % w
 }
 
+// Exit indicates which edge of the grid the primary piece must slide off of
+// to solve the puzzle. The primary piece must slide toward the edge, so
+// ExitRight and ExitLeft require a horizontal primary piece while ExitTop and
+// ExitBottom require a vertical one. Board.ExitLane says where along the
+// edge the exit is.
+type Exit int
+
+const (
+	ExitRight Exit = iota
+	ExitLeft
+	ExitTop
+	ExitBottom
+)
+
+func defaultExit(orientation Orientation) Exit {
+	if orientation == Vertical {
+		return ExitBottom
+	}
+	return ExitRight
+}
+
+func (exit Exit) Orientation() Orientation {
+	if exit == ExitTop || exit == ExitBottom {
+		return Vertical
+	}
+	return Horizontal
+}
+
+func (exit Exit) String() string {
+	switch exit {
+	case ExitRight:
+		return "right"
+	case ExitLeft:
+		return "left"
+	case ExitTop:
+		return "top"
+	case ExitBottom:
+		return "bottom"
+	}
+	return fmt.Sprintf("Exit(%d)", int(exit))
+}
+
 // Move represents a move to make on the board. Piece indicates which piece
 // (by index) to move and Steps is a non-zero positive or negative int that
 // specifies how many cells to move the piece.
@@ -57,8 +111,10 @@ func (move Move) AbsSteps() int {
 	return move.Steps
 }
 
+// Label returns the default label for the moved piece. Use Board.MoveString
+// to format a move with a board's own labels.
 func (move Move) Label() string {
-	return string('A' + move.Piece)
+	return defaultLabel(move.Piece)
 }
 
 func (move Move) String() string {
@@ -66,22 +122,54 @@ func (move Move) String() string {
 }
 
 // Board represents the complete puzzle state. The size of the grid, the
-// placement, size, orientation of the pieces. The placement of walls
-// (immovable obstacles). Which cells are occupied, either by a piece or a
-// wall.
+// placement, size, orientation of the pieces. The label of each piece, if
+// they differ from A, B, C, etc. The placement of walls (immovable
+// obstacles). The edge through which the primary piece exits. Which cells
+// are occupied, either by a piece or a wall. Occupancy is kept in a bitboard
+// (mask) when the grid has at most 64 cells, otherwise in a slice.
+//
+// ExitLane is the row of a left or right exit or the column of a top or
+// bottom exit. If it is nil the exit is in line with the primary piece,
+// wherever that is. A primary piece that is not in line with the exit can
+// never reach it, so such a board is unsolvable.
 type Board struct {
-	Width    int
-	Height   int
-	Pieces   []Piece
-	Walls    []int
-	occupied []bool
-	memoKey  MemoKey
+	Width     int
+	Height    int
+	Pieces    []Piece
+	Labels    []string
+	Walls     []int
+	Exit      Exit
+	ExitLane  *int
+	occupied  []bool
+	mask      uint64
+	bits      *bitMasks
+	memoKey   MemoKey
+	keyShifts []uint16
+	keyExt    []uint64
 }
 
 func NewEmptyBoard(w, h int) *Board {
-	occupied := make([]bool, w*h)
-	memoKey := MakeMemoKey(nil)
-	return &Board{w, h, nil, nil, occupied, memoKey}
+	return newBoard(w, h, nil, nil, nil, ExitRight, nil)
+}
+
+// newBoard allocates a board and marks the cells covered by the given pieces
+// and walls as occupied. They must lie inside the grid.
+func newBoard(w, h int, pieces []Piece, labels []string, walls []int, exit Exit, exitLane *int) *Board {
+	board := &Board{Width: w, Height: h, Labels: labels, Exit: exit, ExitLane: exitLane}
+	board.bits = newBitMasks(w, h)
+	if board.bits == nil {
+		board.occupied = make([]bool, w*h)
+	}
+	for _, i := range walls {
+		board.setCell(i, true)
+	}
+	board.Walls = walls
+	board.Pieces = pieces
+	for _, piece := range pieces {
+		board.setOccupied(piece, true)
+	}
+	board.layoutMemoKey()
+	return board
 }
 
 func NewRandomBoard(w, h, primaryRow, primarySize, numPieces, numWalls int) *Board {
@@ -96,31 +184,76 @@ func NewRandomBoard(w, h, primaryRow, primarySize, numPieces, numWalls int) *Boa
 	return board
 }
 
+// NewBoardFromString parses a single-line board description. Rows may be
+// separated by "/" (or newlines, as produced by Board.String), which allows
+// rectangular boards. Without separators, the description must be a perfect
+// square, as produced by Board.Hash for square boards. An optional suffix
+// such as ":left" overrides the default exit, and one such as ":left:2" also
+// places it in the given row or column.
 func NewBoardFromString(desc string) (*Board, error) {
-	s := int(math.Sqrt(float64(len(desc))))
-	if s*s != len(desc) {
-		return nil, fmt.Errorf("NewBoardFromString only supports square boards")
+	var exitName string
+	if i := strings.IndexByte(desc, ':'); i >= 0 {
+		desc, exitName = desc[:i], desc[i+1:]
+	}
+	var rows []string
+	if strings.ContainsAny(desc, "/\n") {
+		rows = strings.FieldsFunc(desc, func(r rune) bool {
+			return r == '/' || r == '\n'
+		})
+	} else {
+		s := int(math.Sqrt(float64(len(desc))))
+		if s*s != len(desc) {
+			return nil, fmt.Errorf("board description must be square or use / to separate rows")
+		}
+		rows = make([]string, s)
+		for i := range rows {
+			rows[i] = desc[i*s : i*s+s]
+		}
+	}
+	board, err := NewBoard(rows)
+	if board == nil || exitName == "" {
+		return board, err
 	}
-	rows := make([]string, s)
-	for i := range rows {
-		rows[i] = desc[i*s : i*s+s]
+	if i := strings.IndexByte(exitName, ':'); i >= 0 {
+		lane, err := strconv.Atoi(exitName[i+1:])
+		if err != nil || lane < 0 {
+			return nil, fmt.Errorf("invalid exit lane %q", exitName[i+1:])
+		}
+		exitName, board.ExitLane = exitName[:i], &lane
 	}
-	return NewBoard(rows)
+	if err := board.Exit.UnmarshalText([]byte(exitName)); err != nil {
+		return nil, err
+	}
+	return board, board.Validate()
 }
 
+// NewBoard parses a board from its rows. Each piece is a run of cells with
+// the same label, walls are "x" and empty cells are "." or "o". The primary
+// piece is the one labeled A, or R if there is no A, or else the first label
+// in sorted order. The original labels are kept in Board.Labels.
 func NewBoard(desc []string) (*Board, error) {
 	// determine board size
 	h := len(desc)
 	if h < MinBoardSize {
 		return nil, fmt.Errorf("board height must be >= %d", MinBoardSize)
 	}
+	if h > MaxBoardSize {
+		return nil, fmt.Errorf("board height must be <= %d", MaxBoardSize)
+	}
 	w := len(desc[0])
 	if w < MinBoardSize {
 		return nil, fmt.Errorf("board width must be >= %d", MinBoardSize)
 	}
+	if w > MaxBoardSize {
+		return nil, fmt.Errorf("board width must be <= %d", MaxBoardSize)
+	}
+	for _, row := range desc {
+		if len(row) != w {
+			return nil, fmt.Errorf("board rows must all have the same width")
+		}
+	}
 
 	// identify occupied cells and their labels
-	occupied := make([]bool, w*h)
 	positions := make(map[string][]int)
 	var walls []int
 	for y, row := range desc {
@@ -130,7 +263,6 @@ func NewBoard(desc []string) (*Board, error) {
 				continue
 			}
 			i := y*w + x
-			occupied[i] = true
 			if label == "x" {
 				walls = append(walls, i)
 			} else {
@@ -140,12 +272,18 @@ func NewBoard(desc []string) (*Board, error) {
 		}
 	}
 
-	// find and sort distinct piece labels
+	// find and sort distinct piece labels, primary piece first
 	labels := make([]string, 0, len(positions))
 	for label := range positions {
 		labels = append(labels, label)
 	}
 	sort.Strings(labels)
+	if len(labels) > 0 {
+		primary := primaryLabel(labels)
+		i := sort.SearchStrings(labels, primary)
+		copy(labels[1:i+1], labels[:i])
+		labels[0] = primary
+	}
 
 	// validate and create pieces
 	pieces := make([]Piece, 0, len(labels))
@@ -170,8 +308,19 @@ func NewBoard(desc []string) (*Board, error) {
 		pieces = append(pieces, Piece{ps[0], len(ps), dir})
 	}
 
+	// vertical primary pieces exit through the bottom by default
+	exit := ExitRight
+	if len(pieces) > 0 {
+		exit = defaultExit(pieces[0].Orientation)
+	}
+
+	// only keep labels that differ from the defaults
+	if isDefaultLabels(labels) {
+		labels = nil
+	}
+
 	// create board
-	board := &Board{w, h, pieces, walls, occupied, MakeMemoKey(pieces)}
+	board := newBoard(w, h, pieces, labels, walls, exit, nil)
 	return board, board.Validate()
 }
 
@@ -186,7 +335,7 @@ func (board *Board) String() string {
 		grid[i] = "x"
 	}
 	for i, piece := range board.Pieces {
-		label := string('A' + i)
+		label := board.Label(i)
 		idx := piece.Position
 		stride := piece.Stride(w)
 		for j := 0; j < piece.Size; j++ {
@@ -202,6 +351,11 @@ func (board *Board) String() string {
 	return strings.Join(rows, "\n")
 }
 
+// Hash returns a single-line description of the board. Square boards are
+// written as one run of W*H cells. Rectangular boards separate their rows
+// with "/" so that NewBoardFromString can recover the dimensions. Pieces are
+// always labeled A, B, C, etc. by index, ignoring Labels, so that equivalent
+// boards hash the same. After Z come a, b, c, etc. and then digits.
 func (board *Board) Hash() string {
 	w := board.Width
 	h := board.Height
@@ -213,7 +367,10 @@ func (board *Board) Hash() string {
 		grid[i] = 'x'
 	}
 	for i, piece := range board.Pieces {
-		label := rune('A' + i)
+		label := '?'
+		if i < len(labelChars) {
+			label = rune(labelChars[i])
+		}
 		idx := piece.Position
 		stride := 1
 		if piece.Orientation == Vertical {
@@ -224,7 +381,15 @@ func (board *Board) Hash() string {
 			idx += stride
 		}
 	}
-	return string(grid)
+	if w == h {
+		return string(grid)
+	}
+	rows := make([]string, h)
+	for y := 0; y < h; y++ {
+		i := y * w
+		rows[y] = string(grid[i : i+w])
+	}
+	return strings.Join(rows, "/")
 }
 
 func (board *Board) Copy() *Board {
@@ -232,20 +397,145 @@ func (board *Board) Copy() *Board {
 	h := board.Height
 	pieces := make([]Piece, len(board.Pieces))
 	walls := make([]int, len(board.Walls))
-	occupied := make([]bool, len(board.occupied))
-	memoKey := board.memoKey
+	keyShifts := make([]uint16, len(board.keyShifts))
 	copy(pieces, board.Pieces)
+	copy(keyShifts, board.keyShifts)
+	var keyExt []uint64
+	if board.keyExt != nil {
+		keyExt = make([]uint64, len(board.keyExt))
+		copy(keyExt, board.keyExt)
+	}
 	copy(walls, board.Walls)
-	copy(occupied, board.occupied)
-	return &Board{w, h, pieces, walls, occupied, memoKey}
+	var occupied []bool
+	if board.occupied != nil {
+		occupied = make([]bool, len(board.occupied))
+		copy(occupied, board.occupied)
+	}
+	var labels []string
+	if board.Labels != nil {
+		labels = make([]string, len(board.Labels))
+		copy(labels, board.Labels)
+	}
+	exitLane := board.ExitLane
+	if exitLane != nil {
+		lane := *exitLane
+		exitLane = &lane
+	}
+	return &Board{
+		w, h, pieces, labels, walls, board.Exit, exitLane,
+		occupied, board.mask, board.bits, board.memoKey, keyShifts, keyExt}
+}
+
+// piecesByPosition sorts the non-primary pieces of a board, keeping their
+// labels in step.
+type piecesByPosition struct {
+	board *Board
+}
+
+func (a piecesByPosition) Len() int {
+	return len(a.board.Pieces) - 1
+}
+
+func (a piecesByPosition) Less(i, j int) bool {
+	return a.board.Pieces[i+1].Position < a.board.Pieces[j+1].Position
+}
+
+func (a piecesByPosition) Swap(i, j int) {
+	pieces := a.board.Pieces
+	pieces[i+1], pieces[j+1] = pieces[j+1], pieces[i+1]
+	if labels := a.board.Labels; labels != nil {
+		labels[i+1], labels[j+1] = labels[j+1], labels[i+1]
+	}
 }
 
 func (board *Board) SortPieces() {
-	a := board.Pieces[1:]
-	sort.Slice(a, func(i, j int) bool {
-		return a[i].Position < a[j].Position
-	})
-	board.memoKey = MakeMemoKey(board.Pieces)
+	sort.Sort(piecesByPosition{board})
+	board.layoutMemoKey()
+}
+
+// labelChars are the single-character labels, in the order they are given
+// to pieces. Walls and empty cells use "x", "o" and ".".
+const labelChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnpqrstuvwyz0123456789"
+
+// reservedLabelChars mean something else in board and move strings.
+const reservedLabelChars = ".ox/:+-,"
+
+// validLabel reports whether label can stand for a piece in board and move
+// strings: it must be a single printable ASCII character that is not
+// reserved.
+func validLabel(label string) bool {
+	return len(label) == 1 && label[0] > ' ' && label[0] <= '~' &&
+		!strings.Contains(reservedLabelChars, label)
+}
+
+func defaultLabel(i int) string {
+	if i < 0 || i >= len(labelChars) {
+		return "?"
+	}
+	return labelChars[i : i+1]
+}
+
+func isDefaultLabels(labels []string) bool {
+	for i, label := range labels {
+		if label != defaultLabel(i) {
+			return false
+		}
+	}
+	return true
+}
+
+// Label returns the label of the piece with index i.
+// primaryLabel returns the label of the piece that NewBoard makes the
+// primary piece: A, or R if there is no A, or else the first label in sorted
+// order.
+func primaryLabel(labels []string) string {
+	var first string
+	hasR := false
+	for i, label := range labels {
+		if label == "A" {
+			return label
+		}
+		if label == "R" {
+			hasR = true
+		}
+		if i == 0 || label < first {
+			first = label
+		}
+	}
+	if hasR {
+		return "R"
+	}
+	return first
+}
+
+func (board *Board) Label(i int) string {
+	if i < len(board.Labels) {
+		return board.Labels[i]
+	}
+	return defaultLabel(i)
+}
+
+// MoveString is like Move.String but uses the board's piece labels.
+func (board *Board) MoveString(move Move) string {
+	return fmt.Sprintf("%s%+d", board.Label(move.Piece), move.Steps)
+}
+
+// nextLabel returns an unused label for a new piece.
+func (board *Board) nextLabel() (string, error) {
+	for _, r := range labelChars {
+		label := string(r)
+		used := false
+		for _, l := range board.Labels {
+			if l == label {
+				used = true
+				break
+			}
+		}
+		if !used {
+			return label, nil
+		}
+	}
+	return "", fmt.Errorf("no unused piece label")
 }
 
 func (board *Board) HasFullRowOrCol() bool {
@@ -282,27 +572,56 @@ func (board *Board) Validate() error {
 	h := board.Height
 	pieces := board.Pieces
 
-	// board size must be >= MinBoardSize
+	// board size must be >= MinBoardSize and <= MaxBoardSize
 	if w < MinBoardSize {
 		return fmt.Errorf("board width must be >= %d", MinBoardSize)
 	}
 	if h < MinBoardSize {
 		return fmt.Errorf("board height must be >= %d", MinBoardSize)
 	}
+	if w > MaxBoardSize {
+		return fmt.Errorf("board width must be <= %d", MaxBoardSize)
+	}
+	if h > MaxBoardSize {
+		return fmt.Errorf("board height must be <= %d", MaxBoardSize)
+	}
 
 	// board must have at least one piece
 	if len(pieces) < 1 {
 		return fmt.Errorf("board must have at least one piece")
 	}
 
-	// board must have <= MaxPieces
-	if len(pieces) > MaxPieces {
-		return fmt.Errorf("board must have <= %d pieces", MaxPieces)
+	// primary piece offset must fit in its memo key field
+	lane := w
+	if pieces[0].Orientation == Vertical {
+		lane = h
+	}
+	if lane-pieces[0].Size >= 1<<primaryKeyBits {
+		return fmt.Errorf("primary piece lane must be < %d cells longer than the piece", 1<<primaryKeyBits)
+	}
+
+	// exit must be one of the four edges
+	if board.Exit < ExitRight || board.Exit > ExitBottom {
+		return fmt.Errorf("invalid exit %d", int(board.Exit))
+	}
+
+	// exit lane must be a row or column of the grid
+	if board.ExitLane != nil {
+		edge := h
+		if board.Exit.Orientation() == Vertical {
+			edge = w
+		}
+		if lane := *board.ExitLane; lane < 0 || lane >= edge {
+			return fmt.Errorf("exit lane %d is outside of the grid", lane)
+		}
 	}
 
-	// primary piece must be horizontal
-	if pieces[0].Orientation != Horizontal {
-		return fmt.Errorf("primary piece must be horizontal")
+	// primary piece must slide toward the exit
+	if pieces[0].Orientation != board.Exit.Orientation() {
+		if board.Exit.Orientation() == Horizontal {
+			return fmt.Errorf("primary piece must be horizontal for a %s exit", board.Exit)
+		}
+		return fmt.Errorf("primary piece must be vertical for a %s exit", board.Exit)
 	}
 
 	// validate walls
@@ -320,10 +639,27 @@ func (board *Board) Validate() error {
 		occupied[i] = true
 	}
 
+	// validate labels
+	if board.Labels != nil {
+		if len(board.Labels) != len(pieces) {
+			return fmt.Errorf("board must have one label per piece")
+		}
+		seen := make(map[string]bool)
+		for _, label := range board.Labels {
+			if !validLabel(label) {
+				return fmt.Errorf("invalid piece label %q", label)
+			}
+			if seen[label] {
+				return fmt.Errorf("piece label %s is used more than once", label)
+			}
+			seen[label] = true
+		}
+	}
+
 	// validate pieces
-	primaryRow := pieces[0].Row(w)
+	primary := pieces[0]
 	for i, piece := range pieces {
-		label := string('A' + i)
+		label := board.Label(i)
 		row := piece.Row(w)
 		col := piece.Col(w)
 
@@ -332,9 +668,14 @@ func (board *Board) Validate() error {
 			return fmt.Errorf("piece %s must have size >= %d", label, MinPieceSize)
 		}
 
-		// no horizontal pieces can be on the same row as the primary piece
-		if i > 0 && piece.Orientation == Horizontal && row == primaryRow {
-			return fmt.Errorf("no horizontal pieces can be on the primary row")
+		// no pieces can share the primary piece's lane
+		if i > 0 && piece.Orientation == primary.Orientation {
+			if piece.Orientation == Horizontal && row == primary.Row(w) {
+				return fmt.Errorf("no horizontal pieces can be on the primary row")
+			}
+			if piece.Orientation == Vertical && col == primary.Col(w) {
+				return fmt.Errorf("no vertical pieces can be on the primary column")
+			}
 		}
 
 		// pieces must be contained within the grid
@@ -363,7 +704,30 @@ func (board *Board) Validate() error {
 	return nil
 }
 
+// cellOccupied reports whether cell i holds a piece or a wall.
+func (board *Board) cellOccupied(i int) bool {
+	if board.bits != nil {
+		return board.mask&(1<<uint(i)) != 0
+	}
+	return board.occupied[i]
+}
+
+func (board *Board) setCell(i int, value bool) {
+	if board.bits != nil {
+		if value {
+			board.mask |= 1 << uint(i)
+		} else {
+			board.mask &^= 1 << uint(i)
+		}
+		return
+	}
+	board.occupied[i] = value
+}
+
 func (board *Board) isOccupied(piece Piece) bool {
+	if board.bits != nil {
+		return board.mask&board.pieceMask(piece) != 0
+	}
 	idx := piece.Position
 	stride := piece.Stride(board.Width)
 	for i := 0; i < piece.Size; i++ {
@@ -379,42 +743,61 @@ func (board *Board) setOccupied(piece Piece, value bool) {
 	idx := piece.Position
 	stride := piece.Stride(board.Width)
 	for i := 0; i < piece.Size; i++ {
-		board.occupied[idx] = value
+		board.setCell(idx, value)
 		idx += stride
 	}
 }
 
-func (board *Board) addPiece(piece Piece) {
+func (board *Board) addPiece(piece Piece) error {
 	i := len(board.Pieces)
+	if board.Labels != nil {
+		label, err := board.nextLabel()
+		if err != nil {
+			return err
+		}
+		board.Labels = append(board.Labels, label)
+	}
 	board.Pieces = append(board.Pieces, piece)
 	board.setOccupied(piece, true)
-	board.memoKey[i] = piece.Position
+	board.addKeyShift()
+	board.addKeyField(i, 1)
+	return nil
 }
 
+// AddPiece adds the piece if its cells are free and, when the board has
+// labels of its own, one is left for it.
 func (board *Board) AddPiece(piece Piece) bool {
 	if board.isOccupied(piece) {
 		return false
 	}
-	board.addPiece(piece)
-	return true
+	return board.addPiece(piece) == nil
 }
 
 func (board *Board) AddWall(i int) bool {
-	if board.occupied[i] {
+	if board.cellOccupied(i) {
 		return false
 	}
 	board.Walls = append(board.Walls, i)
-	board.occupied[i] = true
+	board.setCell(i, true)
 	return true
 }
 
 func (board *Board) RemovePiece(i int) {
 	board.setOccupied(board.Pieces[i], false)
 	j := len(board.Pieces) - 1
+	if i == j {
+		board.removeKeyShift()
+	}
 	board.Pieces[i] = board.Pieces[j]
-	board.memoKey[i] = board.Pieces[i].Position
 	board.Pieces = board.Pieces[:j]
-	board.memoKey[j] = 0
+	if board.Labels != nil {
+		board.Labels[i] = board.Labels[j]
+		board.Labels = board.Labels[:j]
+	}
+	if i != j {
+		// the moved piece may need a field of a different size
+		board.layoutMemoKey()
+	}
 }
 
 func (board *Board) RemoveLastPiece() {
@@ -422,22 +805,76 @@ func (board *Board) RemoveLastPiece() {
 }
 
 func (board *Board) RemoveWall(i int) {
-	board.occupied[board.Walls[i]] = false
+	board.setCell(board.Walls[i], false)
 	a := board.Walls
 	a[i] = a[len(a)-1]
 	a = a[:len(a)-1]
 	board.Walls = a
 }
 
+// exitLane returns the row or column of the exit.
+func (board *Board) exitLane() int {
+	if board.ExitLane != nil {
+		return *board.ExitLane
+	}
+	piece := board.Pieces[0]
+	if board.Exit.Orientation() == Vertical {
+		return piece.Col(board.Width)
+	}
+	return piece.Row(board.Width)
+}
+
+// Target returns the position the primary piece must reach to exit the grid.
 func (board *Board) Target() int {
 	w := board.Width
+	h := board.Height
 	piece := board.Pieces[0]
-	row := piece.Row(w)
-	return (row+1)*w - piece.Size
+	lane := board.exitLane()
+	switch board.Exit {
+	case ExitLeft:
+		return lane * w
+	case ExitTop:
+		return lane
+	case ExitBottom:
+		return (h-piece.Size)*w + lane
+	default:
+		return (lane+1)*w - piece.Size
+	}
+}
+
+// inLane reports whether piece i can slide to the given position, which
+// must be in its row if it is horizontal or its column if it is vertical.
+func (board *Board) inLane(i, position int) bool {
+	w := board.Width
+	piece := board.Pieces[i]
+	if position < 0 || position >= w*board.Height {
+		return false
+	}
+	if piece.Orientation == Vertical {
+		return position%w == piece.Col(w) && position/w+piece.Size <= board.Height
+	}
+	return position/w == piece.Row(w) && position%w+piece.Size <= w
+}
+
+// piecePath describes the cells that piece i would have to sweep through to
+// reach the given target, which must be in its lane. It returns the first
+// such cell, the stride to walk toward the target and the number of cells
+// along the way.
+func (board *Board) piecePath(i, target int) (int, int, int) {
+	piece := board.Pieces[i]
+	stride := piece.Stride(board.Width)
+	d := target - piece.Position
+	if d >= 0 {
+		return piece.Position + piece.Size*stride, stride, d / stride
+	}
+	return piece.Position - stride, -stride, -d / stride
 }
 
 func (board *Board) Moves(buf []Move) []Move {
 	moves := buf[:0]
+	if board.bits != nil {
+		return board.bitMoves(moves)
+	}
 	w := board.Width
 	h := board.Height
 	for i, piece := range board.Pieces {
@@ -476,6 +913,10 @@ func (board *Board) Moves(buf []Move) []Move {
 }
 
 func (board *Board) DoMove(move Move) {
+	if board.bits != nil {
+		board.bitDoMove(move)
+		return
+	}
 	piece := &board.Pieces[move.Piece]
 	stride := piece.Stride(board.Width)
 
@@ -486,7 +927,7 @@ func (board *Board) DoMove(move Move) {
 	}
 
 	piece.Position += stride * move.Steps
-	board.memoKey[move.Piece] = piece.Position
+	board.moveKeyField(move.Piece, move.Steps)
 
 	idx = piece.Position
 	for i := 0; i < piece.Size; i++ {
@@ -548,19 +989,49 @@ func (board *Board) ReachableStates() int {
 }
 
 func (board *Board) MemoKey() *MemoKey {
+	// ext is only rebuilt when the spilled words have changed, which also
+	// clears it once the key shrinks back to two words
+	if !keyExtEqual(board.memoKey.ext, board.keyExt) {
+		board.memoKey.ext = makeKeyExt(board.keyExt)
+	}
 	return &board.memoKey
 }
 
+// Solve returns an optimal solution of the board. Different boards may be
+// solved from different goroutines at once, but solving moves the board's
+// pieces and puts them back, so one board must not be shared between them.
 func (board *Board) Solve() Solution {
-	return NewSolver(board).Solve()
+	solver := acquireSolver(board)
+	defer releaseSolver(solver)
+	return solver.Solve()
 }
 
 func (board *Board) Unsolve() (*Board, Solution) {
 	return NewUnsolver(board).Unsolve()
 }
 
+// SolveContext is like Solve but stops early if ctx is done.
+func (board *Board) SolveContext(ctx context.Context) Solution {
+	solver := acquireSolver(board)
+	defer releaseSolver(solver)
+	return solver.SolveContext(ctx)
+}
+
+// UnsolveContext is like Unsolve but stops early if ctx is done.
+func (board *Board) UnsolveContext(ctx context.Context) (*Board, Solution) {
+	return NewUnsolver(board).UnsolveContext(ctx)
+}
+
+// ProveUnsolvable explores every state reachable from the board, returning
+// ErrSolvable if any of them is solved.
+func (board *Board) ProveUnsolvable() (*UnsolvableProof, error) {
+	return NewSolver(board).ProveUnsolvable()
+}
+
 func (board *Board) UnsafeSolve() Solution {
-	return NewSolver(board).UnsafeSolve()
+	solver := acquireSolver(board)
+	defer releaseSolver(solver)
+	return solver.UnsafeSolve()
 }
 
 func (board *Board) UnsafeUnsolve() (*Board, Solution) {
@@ -568,7 +1039,7 @@ func (board *Board) UnsafeUnsolve() (*Board, Solution) {
 }
 
 func (board *Board) Render() image.Image {
-	return renderBoard(board)
+	return renderBoard(board, nil)
 }
 
 func (board *Board) Impossible() bool {
@@ -580,16 +1051,47 @@ func (board *Board) BlockedSquares() []int {
 }
 
 func (board *Board) Canonicalize() *Board {
-	bestKey := board.memoKey
+	result, _ := board.CanonicalizeContext(context.Background())
+	return result
+}
+
+// CanonicalizeContext is like Canonicalize but stops early if ctx is done,
+// returning nil and the context's error.
+func (board *Board) CanonicalizeContext(ctx context.Context) (*Board, error) {
+	board = board.Copy()
+	bestKey := *board.MemoKey()
 	bestBoard := board.Copy()
-	for b := range board.StateIterator() {
-		if b.memoKey.Less(&bestKey, true) {
-			bestKey = b.memoKey
-			bestBoard = b.Copy()
+	memo := NewMemo()
+	var err error
+	var f func(int)
+	f = func(previousPiece int) {
+		if err != nil || !memo.Add(board.MemoKey(), 0) {
+			return
+		}
+		if memo.Size()%contextCheckInterval == 0 {
+			if err = ctx.Err(); err != nil {
+				return
+			}
+		}
+		if board.MemoKey().Less(&bestKey, true) {
+			bestKey = *board.MemoKey()
+			bestBoard = board.Copy()
 		}
+		for _, move := range board.Moves(nil) {
+			if move.Piece == previousPiece {
+				continue
+			}
+			board.DoMove(move)
+			f(move.Piece)
+			board.UndoMove(move)
+		}
+	}
+	f(-1)
+	if err != nil {
+		return nil, err
 	}
 	bestBoard.SortPieces()
-	return bestBoard
+	return bestBoard, nil
 }
 
 // random board mutation below
@@ -756,7 +1258,7 @@ func (board *Board) randomWall(maxAttempts int) (int, bool) {
 	n := board.Width * board.Height
 	for i := 0; i < maxAttempts; i++ {
 		p := rand.Intn(n)
-		if !board.occupied[p] {
+		if !board.cellOccupied(p) {
 			return p, true
 		}
 	}
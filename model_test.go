@@ -0,0 +1,340 @@
+package rush
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestNewBoardFromString(t *testing.T) {
+	test := func(desc string, w, h int) {
+		board, err := NewBoardFromString(desc)
+		if err != nil {
+			t.Fatalf("%s: %v", desc, err)
+		}
+		if board.Width != w || board.Height != h {
+			t.Fatalf("%s: got %dx%d, want %dx%d", desc, board.Width, board.Height, w, h)
+		}
+		if hash := board.Hash(); hash != desc {
+			t.Fatalf("%s: Hash() = %s", desc, hash)
+		}
+		other, err := NewBoardFromString(board.String())
+		if err != nil || other.Hash() != desc {
+			t.Fatalf("%s: String() did not round trip", desc)
+		}
+	}
+
+	// square boards keep the flat format
+	test("BBBCDEFGGCDEF.AADEHHI....JI.KK.JLLMM", 6, 6)
+
+	// rectangular boards separate rows with slashes
+	test("..B.C../..B.C../AAB..../DDD..../......./.......", 7, 6)
+	test("B..../B.C../AAC../..D../..D../...../...../.....", 5, 8)
+
+	if _, err := NewBoardFromString("AA.../...../...."); err == nil {
+		t.Fatal("expected error for ragged rows")
+	}
+}
+
+func TestLabels(t *testing.T) {
+	board, err := NewBoard([]string{
+		"..T.CC",
+		"..T...",
+		"RRT...",
+		"DDD..1",
+		".....1",
+		".....1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if board.Label(0) != "R" {
+		t.Fatalf("primary piece is %s, want R", board.Label(0))
+	}
+	other, err := NewBoardFromString(board.String())
+	if err != nil || other.String() != board.String() {
+		t.Fatal("labels did not round trip")
+	}
+	solution := board.Solve()
+	moves, err := board.ParseMoves(solution.String()[strings.Index(solution.String(), ":")+1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := board.CheckSolution(moves); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLabelRoundTrip(t *testing.T) {
+	board, err := NewBoardFromString("..B.CC..B...AAB...DDD..E.....E.....E")
+	if err != nil {
+		t.Fatal(err)
+	}
+	solution := board.Solve()
+
+	// any single printable character that is not reserved round trips
+	for _, labels := range [][]string{
+		{"R", "1", "z", "#", "E"},
+		{"#", "B", "9", "C", "a"},
+		{"A", "~", "!", "*", "$"},
+	} {
+		b := board.Copy()
+		b.Labels = append(labels[:0:0], labels...)
+		if err := b.Validate(); err != nil {
+			t.Fatalf("%v: %v", labels, err)
+		}
+		text, err := b.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		other, err := NewBoardFromString(string(text))
+		if err != nil || other.String() != b.String() || other.Pieces[0] != b.Pieces[0] {
+			t.Fatalf("%v: text did not round trip: %v", labels, err)
+		}
+		data, err := json.Marshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded Board
+		if err := json.Unmarshal(data, &decoded); err != nil || decoded.String() != b.String() {
+			t.Fatalf("%v: JSON did not round trip: %v", labels, err)
+		}
+		moves := make([]string, len(solution.Moves))
+		for i, move := range solution.Moves {
+			moves[i] = b.MoveString(move)
+		}
+		parsed, err := b.ParseMoves(strings.Join(moves, " "))
+		if err != nil || !reflect.DeepEqual(parsed, solution.Moves) {
+			t.Fatalf("%v: moves did not round trip: %v", labels, err)
+		}
+	}
+
+	// labels that would break board or move strings are rejected
+	for _, label := range []string{"", "T1", ".", "o", "x", "/", ":", "+", "-", ",", " ", "\n", "é"} {
+		b := board.Copy()
+		b.Labels = []string{"A", label, "C", "D", "E"}
+		if err := b.Validate(); err == nil {
+			t.Fatalf("expected error for label %q", label)
+		}
+		data := fmt.Sprintf(`{"width":6,"height":6,"pieces":[{"label":"A","position":12,"size":2,"orientation":"horizontal"},{"label":%q,"position":0,"size":2,"orientation":"vertical"}]}`, label)
+		var decoded Board
+		if label != "" && json.Unmarshal([]byte(data), &decoded) == nil {
+			t.Fatalf("expected JSON error for label %q", label)
+		}
+	}
+	if _, err := NewBoardFromString("..B.CC..B...AAB...++D..E.....E.....E"); err == nil {
+		t.Fatal("expected error for a reserved label")
+	}
+
+	// a labeled board refuses pieces once every label is in use
+	b := board.Copy()
+	b.Labels = strings.Split(labelChars, "")
+	if _, err := b.nextLabel(); err == nil {
+		t.Fatal("expected an error when every label is in use")
+	}
+	if b.AddPiece(Piece{30, 2, Horizontal}) || len(b.Pieces) != len(board.Pieces) {
+		t.Fatal("expected no piece to be added without a label")
+	}
+	b.Labels = []string{"A", "B", "C", "D", "E"}
+	if !b.AddPiece(Piece{30, 2, Horizontal}) || b.Label(5) != "F" {
+		t.Fatalf("got label %s for a new piece", b.Label(5))
+	}
+}
+
+func TestBitboardMoves(t *testing.T) {
+	sliceBoard := func(board *Board) *Board {
+		b := board.Copy()
+		b.bits = nil
+		b.mask = 0
+		b.occupied = make([]bool, b.Width*b.Height)
+		for _, i := range b.Walls {
+			b.occupied[i] = true
+		}
+		for _, piece := range b.Pieces {
+			b.setOccupied(piece, true)
+		}
+		return b
+	}
+	for _, size := range []int{4, 6, 8} {
+		for i := 0; i < 1000; i++ {
+			board := NewRandomBoard(size, size, size/2-1, 2, size+4, 0)
+			other := sliceBoard(board)
+			// walk both boards through the same random moves
+			for j := 0; j < 10; j++ {
+				a := board.Moves(nil)
+				b := other.Moves(nil)
+				if !reflect.DeepEqual(a, b) {
+					t.Fatalf("moves differ on\n%s\n%v\n%v", board, a, b)
+				}
+				if len(a) == 0 {
+					break
+				}
+				move := a[(i+j)%len(a)]
+				board.DoMove(move)
+				other.DoMove(move)
+			}
+		}
+	}
+}
+
+// exitVariants returns the board with its exit on each edge, by mirroring
+// and transposing its grid, and for each one the square that each square of
+// the original maps to.
+func exitVariants(t *testing.T, board *Board) (map[Exit]*Board, map[Exit]func(int) int) {
+	w, h := board.Width, board.Height
+	grid := strings.Split(board.String(), "\n")
+	transform := func(rows []string, f func(x, y int) (int, int), tw, th int) *Board {
+		cells := make([][]byte, th)
+		for y := range cells {
+			cells[y] = make([]byte, tw)
+		}
+		for y, row := range rows {
+			for x := range row {
+				tx, ty := f(x, y)
+				cells[ty][tx] = row[x]
+			}
+		}
+		desc := make([]string, th)
+		for y := range cells {
+			desc[y] = string(cells[y])
+		}
+		result, err := NewBoard(desc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	id := func(x, y int) (int, int) { return x, y }
+	mirror := func(x, y int) (int, int) { return w - 1 - x, y }
+	transpose := func(x, y int) (int, int) { return y, x }
+	flip := func(x, y int) (int, int) { return y, w - 1 - x }
+	boards := map[Exit]*Board{
+		ExitRight:  transform(grid, id, w, h),
+		ExitLeft:   transform(grid, mirror, w, h),
+		ExitBottom: transform(grid, transpose, h, w),
+		ExitTop:    transform(grid, flip, h, w),
+	}
+	boards[ExitLeft].Exit = ExitLeft
+	boards[ExitTop].Exit = ExitTop
+	squares := make(map[Exit]func(int) int)
+	for exit, f := range map[Exit]func(x, y int) (int, int){
+		ExitRight: id, ExitLeft: mirror, ExitBottom: transpose, ExitTop: flip,
+	} {
+		f, tw := f, boards[exit].Width
+		squares[exit] = func(i int) int {
+			x, y := f(i%w, i/w)
+			return y*tw + x
+		}
+	}
+	for exit, b := range boards {
+		if err := b.Validate(); err != nil {
+			t.Fatalf("%s: %v", exit, err)
+		}
+	}
+	return boards, squares
+}
+
+func TestExits(t *testing.T) {
+	// a solvable board has the same solution length through every edge
+	board, err := NewBoardFromString("BB.C..D..C..DAAC..D.EE..F.....F.GGG.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := board.Solve()
+	solvable, _ := exitVariants(t, board)
+	for exit, b := range solvable {
+		solution := b.Solve()
+		if solution.NumMoves != want.NumMoves {
+			t.Fatalf("%s: got %s, want %s", exit, solution, want)
+		}
+		if err := b.CheckSolution(solution.Moves); err != nil {
+			t.Fatalf("%s: %v", exit, err)
+		}
+		if got := NewBFSSolver(b).Solve(); got.NumMoves != want.NumMoves {
+			t.Fatalf("%s: BFS got %d moves, want %d", exit, got.NumMoves, want.NumMoves)
+		}
+		if m := (BlockerHeuristic{}).MinMoves(b); m != (BlockerHeuristic{}).MinMoves(board) {
+			t.Fatalf("%s: lower bound %d", exit, m)
+		}
+		if b.Impossible() {
+			t.Fatalf("%s: expected not impossible", exit)
+		}
+	}
+
+	// static analysis blocks the same squares through every edge
+	board, err = NewBoardFromString("FF.BC....BC.AA.BC....DDDHHH...EEEGGG")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := board.ExplainImpossible()
+	boards, squares := exitVariants(t, board)
+	for exit, b := range boards {
+		got := b.BlockedSquares()
+		var expected []int
+		for _, i := range board.BlockedSquares() {
+			expected = append(expected, squares[exit](i))
+		}
+		sort.Ints(expected)
+		if !b.Impossible() || !reflect.DeepEqual(got, expected) {
+			t.Fatalf("%s: got blocked squares %v, want %v", exit, got, expected)
+		}
+		if f := b.ExplainImpossible(); f.Square != squares[exit](e.Square) {
+			t.Fatalf("%s: got square %d, want %d", exit, f.Square, squares[exit](e.Square))
+		}
+		if b.Solve().Solvable {
+			t.Fatalf("%s: expected unsolvable", exit)
+		}
+	}
+
+	// an exit in the primary piece's lane is the same as the default, but
+	// one in another lane can never be reached
+	board, err = NewBoardFromString("BB.C..D..C..DAAC..D.EE..F.....F.GGG.:right:2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if solution := board.Solve(); solution.NumMoves != want.NumMoves {
+		t.Fatalf("got %s, want %s", solution, want)
+	}
+	for exit, b := range solvable {
+		lane := (b.exitLane() + 1) % 6
+		b.ExitLane = &lane
+		if !b.Impossible() || b.Solve().Solvable || NewBFSSolver(b).Solve().Solvable {
+			t.Fatalf("%s: expected an exit out of line to be unsolvable", exit)
+		}
+		if e := b.ExplainImpossible(); !e.Impossible || e.Square != -1 || len(e.Chain()) != 0 {
+			t.Fatalf("%s: unexpected explanation %s", exit, e)
+		}
+		if m := (PieceTarget{0, b.Target()}).MinMoves(b); m != unsolvableMoves {
+			t.Fatalf("%s: got lower bound %d", exit, m)
+		}
+	}
+	// the enumerator puts the primary piece at the exit on every edge
+	for exit := ExitRight; exit <= ExitBottom; exit++ {
+		n := 0
+		for item := range NewEnumeratorWithExit(4, 4, exit, 1, 2, 2, 3).Enumerate(16) {
+			b := item.Board
+			if err := b.Validate(); err != nil {
+				t.Fatalf("%s: %v", exit, err)
+			}
+			if b.Exit != exit || b.exitLane() != 1 || b.Pieces[0].Position != b.Target() {
+				t.Fatalf("%s: primary piece is not at the exit:\n%s", exit, b)
+			}
+			n++
+		}
+		if n == 0 {
+			t.Fatalf("%s: expected boards", exit)
+		}
+	}
+
+	lane := 6
+	board.ExitLane = &lane
+	if board.Validate() == nil {
+		t.Fatal("expected error for an exit lane outside of the grid")
+	}
+	if _, err := NewBoardFromString("BB.C..D..C..DAAC..D.EE..F.....F.GGG.:right:x"); err == nil {
+		t.Fatal("expected error for a bad exit lane")
+	}
+}
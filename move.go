@@ -0,0 +1,158 @@
+package rush
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseMove parses a single move in the "A+1" notation produced by
+// Move.String.
+func ParseMove(s string) (Move, error) {
+	return parseMove(s, nil)
+}
+
+// ParseMoves parses a sequence of moves separated by whitespace and/or
+// commas, such as "A-1, C+2, B+1".
+func ParseMoves(s string) ([]Move, error) {
+	return parseMoves(s, nil)
+}
+
+// ParseMove is like the ParseMove function but uses the board's piece
+// labels, as produced by Board.MoveString.
+func (board *Board) ParseMove(s string) (Move, error) {
+	return parseMove(s, board.Labels)
+}
+
+// ParseMoves is like the ParseMoves function but uses the board's piece
+// labels.
+func (board *Board) ParseMoves(s string) ([]Move, error) {
+	return parseMoves(s, board.Labels)
+}
+
+// parseMove looks labels up in the given table, or uses the default labels
+// if it is nil.
+func parseMove(s string, labels []string) (Move, error) {
+	i := strings.IndexAny(s, "+-")
+	if i < 1 {
+		return Move{}, fmt.Errorf("invalid move %q", s)
+	}
+	label := s[:i]
+	piece := -1
+	if labels == nil {
+		if len(label) == 1 {
+			piece = strings.IndexByte(labelChars, label[0])
+		}
+	} else {
+		for j, l := range labels {
+			if l == label {
+				piece = j
+				break
+			}
+		}
+	}
+	if piece < 0 {
+		return Move{}, fmt.Errorf("invalid move %q: unknown piece %s", s, label)
+	}
+	steps, err := strconv.Atoi(s[i:])
+	if err != nil || steps == 0 {
+		return Move{}, fmt.Errorf("invalid move %q", s)
+	}
+	return Move{piece, steps}, nil
+}
+
+func parseMoves(s string, labels []string) ([]Move, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+	moves := make([]Move, len(fields))
+	for i, field := range fields {
+		move, err := parseMove(field, labels)
+		if err != nil {
+			return nil, fmt.Errorf("move %d: %v", i+1, err)
+		}
+		moves[i] = move
+	}
+	return moves, nil
+}
+
+// MoveError reports an illegal move within a sequence of moves. Index is
+// zero-based.
+type MoveError struct {
+	Index  int
+	Move   Move
+	Reason string
+	text   string
+}
+
+func (e *MoveError) Error() string {
+	text := e.text
+	if text == "" {
+		text = e.Move.String()
+	}
+	return fmt.Sprintf("move %d (%s): %s", e.Index+1, text, e.Reason)
+}
+
+// ValidateMove returns an error if move cannot be made on the board in its
+// current state. Unlike DoMove, it checks that the piece exists, that it
+// stays inside the grid and that nothing is in its way.
+func (board *Board) ValidateMove(move Move) error {
+	if move.Piece < 0 || move.Piece >= len(board.Pieces) {
+		return fmt.Errorf("piece %d does not exist", move.Piece)
+	}
+	if move.Steps == 0 {
+		return fmt.Errorf("move must have non-zero steps")
+	}
+	w := board.Width
+	piece := board.Pieces[move.Piece]
+	lane, offset := board.Width, piece.Col(w)
+	if piece.Orientation == Vertical {
+		lane, offset = board.Height, piece.Row(w)
+	}
+	if p := offset + move.Steps; p < 0 || p+piece.Size > lane {
+		return fmt.Errorf("piece %s would leave the grid", board.Label(move.Piece))
+	}
+	target := piece.Position + move.Steps*piece.Stride(w)
+	idx, stride, n := board.piecePath(move.Piece, target)
+	for i := 0; i < n; i++ {
+		if board.cellOccupied(idx) {
+			return fmt.Errorf("piece %s is blocked", board.Label(move.Piece))
+		}
+		idx += stride
+	}
+	return nil
+}
+
+// ApplyMoves makes each move in turn, checking that it is legal first. If a
+// move is illegal, the board is restored to its original state and a
+// *MoveError identifying the move is returned.
+func (board *Board) ApplyMoves(moves []Move) error {
+	for i, move := range moves {
+		if err := board.ValidateMove(move); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				board.UndoMove(moves[j])
+			}
+			return &MoveError{i, move, err.Error(), board.MoveString(move)}
+		}
+		board.DoMove(move)
+	}
+	return nil
+}
+
+// Solved reports whether the primary piece is at its target.
+func (board *Board) Solved() bool {
+	return board.Pieces[0].Position == board.Target()
+}
+
+// CheckSolution returns nil if the moves are all legal and leave the board
+// solved. The board itself is not modified.
+func (board *Board) CheckSolution(moves []Move) error {
+	board = board.Copy()
+	if err := board.ApplyMoves(moves); err != nil {
+		return err
+	}
+	if !board.Solved() {
+		return fmt.Errorf("moves do not solve the board")
+	}
+	return nil
+}
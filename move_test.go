@@ -0,0 +1,49 @@
+package rush
+
+import "testing"
+
+func TestApplyMoves(t *testing.T) {
+	board, err := NewBoardFromString("..B.CC..B...AAB...DDD..E.....E.....E")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := board.Hash()
+
+	test := func(s string, index int) {
+		moves, err := ParseMoves(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = board.ApplyMoves(moves)
+		if index < 0 {
+			if err != nil {
+				t.Fatalf("%s: %v", s, err)
+			}
+			return
+		}
+		e, ok := err.(*MoveError)
+		if !ok || e.Index != index {
+			t.Fatalf("%s: expected error at move %d, got %v", s, index+1, err)
+		}
+		if board.Hash() != hash {
+			t.Fatalf("%s: board was modified", s)
+		}
+	}
+
+	test("C-1, A+2", 1) // A is blocked by B
+	test("E-4", 0)      // off the grid
+	test("F+1", 0)      // no such piece
+	test("C-1 D+3 E-3", 1)
+
+	moves, _ := ParseMoves("C-1")
+	if err := board.CheckSolution(moves); err == nil {
+		t.Fatal("expected unsolved error")
+	}
+	if err := board.CheckSolution(board.Solve().Moves); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ParseMoves("A+1 B2"); err == nil {
+		t.Fatal("expected parse error")
+	}
+}
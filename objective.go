@@ -0,0 +1,221 @@
+package rush
+
+import "sort"
+
+/*
+
+By default Unsolver looks for the single hardest state of a cluster. For
+designing levels it can instead rank the states by an Objective, which scores
+each solvable state and may leave some out, and return the best few. Ties
+between equal scores are broken as for Cluster.Unsolved: more moves, then
+more steps, then first by MemoKey.Less. The steps are those of each state's
+solution of the fewest steps, which the cluster already knows, so ranking by
+Hardest may pick a different state than Unsolve without an objective, which
+counts the steps of the solver's solutions.
+
+Constrain restricts an objective to the states meeting some conditions, such
+as PrimaryAt and PieceUntouched.
+
+*/
+
+// An Objective scores a solvable state of a cluster, with higher scores
+// preferred. States for which it returns false are left out. It is only
+// called with solvable states.
+type Objective func(cluster *Cluster, board *Board) (float64, bool)
+
+// Hardest scores a state by the number of moves needed to solve it.
+func Hardest(cluster *Cluster, board *Board) (float64, bool) {
+	d, _ := cluster.Distance(board)
+	return float64(d), d >= 0
+}
+
+// A Constraint reports whether a state of a cluster may be chosen.
+type Constraint func(cluster *Cluster, board *Board) bool
+
+// Constrain returns an objective that scores states as objective does but
+// leaves out those that do not meet every constraint.
+func Constrain(objective Objective, constraints ...Constraint) Objective {
+	return func(cluster *Cluster, board *Board) (float64, bool) {
+		for _, constraint := range constraints {
+			if !constraint(cluster, board) {
+				return 0, false
+			}
+		}
+		return objective(cluster, board)
+	}
+}
+
+// PrimaryAt requires the primary piece to be at the given offset along its
+// lane, which is its column if it is horizontal.
+func PrimaryAt(offset int) Constraint {
+	return func(cluster *Cluster, board *Board) bool {
+		piece := board.Pieces[0]
+		if piece.Orientation == Vertical {
+			return piece.Row(board.Width) == offset
+		}
+		return piece.Col(board.Width) == offset
+	}
+}
+
+// PieceUntouched requires the given piece not to move in the state's
+// solution. The solution of a state continues with the solution of the next
+// state along it, so the answers are remembered in the cluster and each
+// solution is followed only as far as a state already decided. The same
+// cluster must therefore not be checked from more than one goroutine at a
+// time, which Unsolver never does as it builds a cluster for each unsolve.
+func PieceUntouched(piece int) Constraint {
+	return func(cluster *Cluster, board *Board) bool {
+		if d, _ := cluster.Distance(board); d < 0 {
+			return false
+		}
+		cache := cluster.untouched[piece]
+		if cache == nil {
+			if cluster.untouched == nil {
+				cluster.untouched = make(map[int]*untouchedCache)
+			}
+			cache = &untouchedCache{untouched: make(map[MemoKey]bool)}
+			cluster.untouched[piece] = cache
+		}
+		// follow the solution to a decided state, the solved state or a
+		// move of the piece
+		result := true
+		moves, keys := cache.moves[:0], cache.keys[:0]
+		for {
+			key := *board.MemoKey()
+			if r, ok := cache.untouched[key]; ok {
+				result = r
+				break
+			}
+			keys = append(keys, key)
+			var move Move
+			var ok bool
+			move, ok, cache.buf = cluster.nextMove(board, cache.buf)
+			if !ok {
+				break
+			}
+			if move.Piece == piece {
+				// the states after this one are not decided
+				result = false
+				break
+			}
+			board.DoMove(move)
+			moves = append(moves, move)
+		}
+		for i := len(moves) - 1; i >= 0; i-- {
+			board.UndoMove(moves[i])
+		}
+		for _, key := range keys {
+			cache.untouched[key] = result
+		}
+		cache.moves, cache.keys = moves, keys
+		return result
+	}
+}
+
+// untouchedCache holds the answers of PieceUntouched for one piece of a
+// cluster, along with buffers reused between calls.
+type untouchedCache struct {
+	untouched  map[MemoKey]bool
+	moves, buf []Move
+	keys       []MemoKey
+}
+
+// UnsolveResult is one of the states chosen by Unsolver.UnsolveN.
+type UnsolveResult struct {
+	Board    *Board
+	Solution Solution
+	Score    float64
+}
+
+// SetObjective sets the objective that later unsolves maximize. If objective
+// is nil, Unsolve again looks for the hardest state as described there and
+// UnsolveN ranks by Hardest. If no state meets the objective, Unsolve returns
+// the starting board.
+func (u *Unsolver) SetObjective(objective Objective) {
+	u.objective = objective
+}
+
+// UnsolveN returns up to n distinct states of the board's cluster with the
+// best scores, best first. It returns nothing for an unsolvable board, and
+// an error if the unsolver's limits are reached.
+func (u *Unsolver) UnsolveN(n int) ([]UnsolveResult, error) {
+	if n <= 0 || !u.possible() {
+		return nil, nil
+	}
+	cluster, err := u.cluster()
+	if err != nil {
+		return nil, err
+	}
+	return u.rank(cluster, n)
+}
+
+// rank scores every state of cluster and returns the best n.
+func (u *Unsolver) rank(cluster *Cluster, n int) ([]UnsolveResult, error) {
+	if !cluster.Solvable {
+		return nil, nil
+	}
+	objective := u.objective
+	if objective == nil {
+		objective = Hardest
+	}
+	type ranked struct {
+		board *Board
+		score float64
+		state clusterState
+	}
+	better := func(a, b ranked) bool {
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if a.state.distance != b.state.distance {
+			return a.state.distance > b.state.distance
+		}
+		if a.state.steps != b.state.steps {
+			return a.state.steps > b.state.steps
+		}
+		return a.board.MemoKey().Less(b.board.MemoKey(), true)
+	}
+	// the states were charged to the node limit when the cluster was built,
+	// so walking them again only checks the context
+	var abort func(int) error
+	if ctx := u.solver.ctx; ctx != nil {
+		abort = func(states int) error {
+			if states%contextCheckInterval == 0 {
+				return ctx.Err()
+			}
+			return nil
+		}
+	}
+	var best []ranked // best first
+	err := cluster.each(func(board *Board) {
+		state := cluster.states[*board.MemoKey()]
+		if state.distance < 0 {
+			return
+		}
+		score, ok := objective(cluster, board)
+		if !ok {
+			return
+		}
+		r := ranked{board, score, state}
+		i := sort.Search(len(best), func(i int) bool { return better(r, best[i]) })
+		if i >= n {
+			return
+		}
+		r.board = board.Copy()
+		if len(best) < n {
+			best = append(best, ranked{})
+		}
+		copy(best[i+1:], best[i:])
+		best[i] = r
+	}, abort)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]UnsolveResult, len(best))
+	for i, r := range best {
+		solution := cluster.Solution(r.board)
+		solution.Nodes = u.solver.nodes
+		results[i] = UnsolveResult{r.board, solution, r.score}
+	}
+	return results, nil
+}
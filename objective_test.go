@@ -0,0 +1,158 @@
+package rush
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestUnsolveN(t *testing.T) {
+	board, err := NewBoardFromString("BB.C..D..C..DAAC..D.EE..F.....F.GGG.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsolver := NewUnsolver(board)
+	results, err := unsolver.UnsolveN(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 5 {
+		t.Fatalf("got %d results, want 5", len(results))
+	}
+	cluster := NewCluster(board)
+	solution := cluster.Solution(cluster.Unsolved)
+	if results[0].Board.String() != cluster.Unsolved.String() || results[0].Solution.String() != solution.String() {
+		t.Fatalf("got %s, want %s", results[0].Solution, solution)
+	}
+	seen := make(map[MemoKey]bool)
+	for i, r := range results {
+		if i > 0 && r.Score > results[i-1].Score {
+			t.Fatal("results are not in order")
+		}
+		if r.Score != float64(r.Solution.NumMoves) {
+			t.Fatalf("got score %g for %d moves", r.Score, r.Solution.NumMoves)
+		}
+		if seen[*r.Board.MemoKey()] {
+			t.Fatal("results are not distinct")
+		}
+		seen[*r.Board.MemoKey()] = true
+		if err := r.Board.CheckSolution(r.Solution.Moves); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the hardest state with the primary piece where it starts whose
+	// solution leaves piece 1 alone
+	constraints := []Constraint{PrimaryAt(1), PieceUntouched(1)}
+	want := -1
+	for b := range board.StateIterator() {
+		if constraints[0](cluster, b) && constraints[1](cluster, b) {
+			d, _ := cluster.Distance(b)
+			want = maxInt(want, d)
+		}
+	}
+	if want <= 0 {
+		t.Fatal("expected a state meeting the constraints")
+	}
+	unsolver.SetObjective(Constrain(Hardest, constraints...))
+	constrained, solution := unsolver.Unsolve()
+	if solution.NumMoves != want || constrained.Pieces[0].Col(board.Width) != 1 {
+		t.Fatalf("got %s, want %d moves", solution, want)
+	}
+	for _, move := range solution.Moves {
+		if move.Piece == 1 {
+			t.Fatal("expected piece 1 to stay put")
+		}
+	}
+
+	// the constraints can be shared by unsolvers running at once
+	var wg sync.WaitGroup
+	got := make([]*Board, 4)
+	for i := range got {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			u := NewUnsolver(board)
+			u.SetObjective(Constrain(Hardest, constraints...))
+			got[i], _ = u.Unsolve()
+		}(i)
+	}
+	wg.Wait()
+	for _, b := range got {
+		if b.String() != constrained.String() {
+			t.Fatalf("got\n%s\nwant\n%s", b, constrained)
+		}
+	}
+
+	// a custom score: the easiest unsolved state
+	unsolver.SetObjective(func(cluster *Cluster, board *Board) (float64, bool) {
+		d, _ := cluster.Distance(board)
+		return float64(-d), d > 0
+	})
+	if _, solution := unsolver.Unsolve(); solution.NumMoves != 1 {
+		t.Fatalf("got %s, want 1 move", solution)
+	}
+
+	other, err := NewBoardFromString("..BBBD..E..DAAE..D..EHHHCC.F...GGF..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results, err := NewUnsolver(other).UnsolveN(5); len(results) != 0 || err != nil {
+		t.Fatalf("got %d results and %v for an unsolvable board", len(results), err)
+	}
+}
+
+func TestObjectiveLimits(t *testing.T) {
+	board, err := NewBoardFromString("BB.C..D..C..DAAC..D.EE..F.....F.GGG.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster := NewCluster(board)
+	objective := Constrain(func(cluster *Cluster, board *Board) (float64, bool) {
+		d, _ := cluster.Distance(board)
+		if d < 0 {
+			t.Fatal("objective called with an unsolvable state")
+		}
+		return Hardest(cluster, board)
+	}, PieceUntouched(1))
+
+	// each state is charged to the node limit once
+	unsolver := NewUnsolver(board)
+	unsolver.SetObjective(objective)
+	unsolver.SetLimits(Limits{MaxNodes: uint64(cluster.NumStates) + 1})
+	results, err := unsolver.UnsolveN(1)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("got %d results and %v", len(results), err)
+	}
+
+	// the objective decides with limits as it does without them
+	want, wantSolution := results[0].Board, results[0].Solution
+	unsolver = NewUnsolver(board)
+	unsolver.SetObjective(objective)
+	unsolver.SetLimits(Limits{MaxNodes: 1 << 40})
+	got, solution := unsolver.Unsolve()
+	if got.String() != want.String() || solution.String() != wantSolution.String() {
+		t.Fatalf("got %s, want %s", solution, wantSolution)
+	}
+
+	// PieceUntouched agrees with the solutions it follows, across clusters
+	other, err := NewBoardFromString("..B.CC..B...AAB...DDD..E.....E.....E")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, piece := range []int{1, 4} {
+		untouched := PieceUntouched(piece)
+		for _, b := range []*Board{board, other, board} {
+			c := NewCluster(b)
+			for s := range b.StateIterator() {
+				want := true
+				for _, move := range c.Solution(s).Moves {
+					want = want && move.Piece != piece
+				}
+				key := *s.MemoKey()
+				if untouched(c, s) != want || *s.MemoKey() != key {
+					t.Fatalf("piece %d: wrong answer for\n%s", piece, s)
+				}
+			}
+		}
+	}
+}
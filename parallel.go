@@ -0,0 +1,256 @@
+package rush
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+/*
+
+The parallel solver runs the same iterative deepening search as Solver, but
+splits each iteration into many independent searches. The first few levels of
+the search tree are expanded breadth first until there are enough nodes to
+keep every worker busy, and the workers then take nodes from this frontier
+and search below them. All workers share one transposition table, so a state
+searched by one worker is not searched again by another.
+
+Every search in an iteration has the same depth limit, so the first solution
+found by any worker is optimal. The remaining workers are then cancelled.
+
+*/
+
+// frontierPerWorker is how many frontier nodes to aim for per worker, so
+// that workers that finish early can pick up more work.
+const frontierPerWorker = 16
+
+// NewParallelSolver returns a Solver that searches with the given number of
+// goroutines, or GOMAXPROCS goroutines if workers <= 0. It finds solutions
+// of the same length as Solve, though not necessarily the same moves. Any
+// Goal or Heuristic it uses must be safe to call from several goroutines.
+func NewParallelSolver(board *Board, workers int) *Solver {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	solver := NewSolver(board)
+	solver.memo = newSharedMemo()
+	solver.workers = workers
+	return solver
+}
+
+type frontierNode struct {
+	board         *Board
+	path          []Move
+	previousPiece int
+}
+
+// split expands the search breadth first from the board, returning the
+// frontier nodes and their depth.
+func (solver *Solver) split(maxDepth int) ([]frontierNode, int) {
+	level := []frontierNode{{solver.board.Copy(), nil, -1}}
+	depth := 0
+	for depth < maxDepth-1 && len(level) < solver.workers*frontierPerWorker {
+		height := maxDepth - depth
+		seen := make(map[MemoKey]bool)
+		var next []frontierNode
+		for _, node := range level {
+			board := node.board
+			solver.nodes++
+			if !solver.memo.Add(board.MemoKey(), height) {
+				continue
+			}
+			if solver.minMoves(board) > height {
+				continue
+			}
+			for _, move := range board.Moves(nil) {
+				if move.Piece == node.previousPiece {
+					continue
+				}
+				b := board.Copy()
+				b.DoMove(move)
+				if seen[*b.MemoKey()] {
+					continue
+				}
+				seen[*b.MemoKey()] = true
+				path := make([]Move, depth+1)
+				copy(path, node.path)
+				path[depth] = move
+				next = append(next, frontierNode{b, path, move.Piece})
+			}
+		}
+		level = next
+		depth++
+	}
+	return level, depth
+}
+
+// parallelSearch runs one iteration of the search on all workers.
+func (solver *Solver) parallelSearch(maxDepth int) bool {
+	frontier, depth := solver.split(maxDepth)
+	if len(frontier) == 0 {
+		return false
+	}
+
+	parent := solver.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	// the node budget left is shared out evenly
+	limits := solver.limits
+	if limits.MaxNodes > 0 {
+		if solver.nodes >= limits.MaxNodes {
+			solver.aborted = ErrNodeLimit
+			return false
+		}
+		limits.MaxNodes = (limits.MaxNodes-solver.nodes)/uint64(solver.workers) + 1
+	}
+
+	var next int64
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var path []Move
+	var aborted error
+	for w := 0; w < solver.workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			worker := Solver{
+				goal:      solver.goal,
+				heuristic: solver.heuristic,
+				memo:      solver.memo,
+				path:      make([]Move, maxDepth),
+				moves:     make([][]Move, maxDepth),
+				limits:    limits,
+				ctx:       ctx,
+			}
+			found := false
+			for {
+				i := int(atomic.AddInt64(&next, 1) - 1)
+				if i >= len(frontier) {
+					break
+				}
+				node := frontier[i]
+				worker.board = node.board
+				copy(worker.path, node.path)
+				if worker.search(depth, maxDepth, node.previousPiece) {
+					found = true
+					cancel()
+					break
+				}
+				if worker.aborted != nil {
+					break
+				}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			solver.nodes += worker.nodes
+			if found && path == nil {
+				path = worker.path
+			} else if worker.aborted != nil && worker.aborted != context.Canceled && aborted == nil {
+				aborted = worker.aborted
+			}
+		}()
+	}
+	wg.Wait()
+
+	if path != nil {
+		solver.path = path
+		return true
+	}
+	if aborted == nil {
+		aborted = parent.Err()
+	}
+	solver.aborted = aborted
+	return false
+}
+
+/*
+
+sharedMemo is a transposition table for concurrent use. Keys are spread over
+a number of shards, each a Memo with its own lock, so that workers rarely
+wait on each other.
+
+*/
+
+const memoShards = 64
+
+type memoShard struct {
+	sync.Mutex
+	memo *Memo
+}
+
+type sharedMemo struct {
+	shards [memoShards]memoShard
+	size   int64
+	hits   uint64
+	misses uint64
+}
+
+func newSharedMemo() *sharedMemo {
+	m := &sharedMemo{}
+	for i := range m.shards {
+		m.shards[i].memo = NewMemo()
+	}
+	return m
+}
+
+func (m *sharedMemo) shard(key *MemoKey) *memoShard {
+	// the high bits are independent of the low bits used by each Memo
+	h := hashWords(key.words[:])
+	return &m.shards[h>>58]
+}
+
+func (m *sharedMemo) Add(key *MemoKey, depth int) bool {
+	atomic.AddUint64(&m.hits, 1)
+	s := m.shard(key)
+	s.Lock()
+	size := s.memo.Size()
+	result := s.memo.Add(key, depth)
+	if s.memo.Size() != size {
+		atomic.AddInt64(&m.size, 1)
+		atomic.AddUint64(&m.misses, 1)
+	}
+	s.Unlock()
+	return result
+}
+
+func (m *sharedMemo) Set(key *MemoKey, depth int) {
+	s := m.shard(key)
+	s.Lock()
+	size := s.memo.Size()
+	s.memo.Set(key, depth)
+	if s.memo.Size() != size {
+		atomic.AddInt64(&m.size, 1)
+	}
+	s.Unlock()
+}
+
+func (m *sharedMemo) Size() int {
+	return int(atomic.LoadInt64(&m.size))
+}
+
+func (m *sharedMemo) Hits() uint64 {
+	return atomic.LoadUint64(&m.hits)
+}
+
+func (m *sharedMemo) Misses() uint64 {
+	return atomic.LoadUint64(&m.misses)
+}
+
+func (m *sharedMemo) Evictions() uint64 {
+	return 0
+}
+
+// Clear must not be called while the memo is in use.
+func (m *sharedMemo) Clear() {
+	for i := range m.shards {
+		m.shards[i].memo.Clear()
+	}
+	m.size = 0
+	m.hits = 0
+	m.misses = 0
+}
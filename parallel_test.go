@@ -0,0 +1,26 @@
+package rush
+
+import "testing"
+
+func TestParallelSolver(t *testing.T) {
+	descs := []string{
+		"BBBCDEFGGCDEF.AADEHHI....JI.KK.JLLMM",
+		"..B.CC..B...AAB...DDD..E.....E.....E",
+		"BB.C..D..C..DAAC..D.EE..F.....F.GGG.",
+		"BCDDE.BCF.EGB.FAAGHHHI.G..JIKKLLJMM.",
+	}
+	for _, desc := range descs {
+		board, err := NewBoardFromString(desc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := board.Solve()
+		solution := NewParallelSolver(board, 4).Solve()
+		if solution.NumMoves != expected.NumMoves {
+			t.Fatalf("%s: got %d moves, want %d", desc, solution.NumMoves, expected.NumMoves)
+		}
+		if err := board.CheckSolution(solution.Moves); err != nil {
+			t.Fatalf("%s: %v", desc, err)
+		}
+	}
+}
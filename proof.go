@@ -0,0 +1,112 @@
+package rush
+
+import "errors"
+
+/*
+
+Solver decides that a board is unsolvable when its memo stops growing for a
+while, and the static analyzer only recognizes some unsolvable boards. Both
+are fast but neither is a proof. ProveUnsolvable instead visits every state
+reachable from the board, checking each against the goal, so an
+UnsolvableProof is certain. A Solver in exact mode proves every unsolvable
+verdict this way before returning it.
+
+*/
+
+// UnsolvableProof records an exhaustive search of the states reachable from
+// a board, none of which satisfies the goal. Canonical is the board that
+// Canonicalize would return, which identifies the cluster regardless of
+// where it was entered.
+type UnsolvableProof struct {
+	States    int    `json:"states"`
+	Canonical *Board `json:"canonical"`
+}
+
+var ErrSolvable = errors.New("board is solvable")
+
+// SetExact turns exact mode on or off. In exact mode the solver neither
+// trusts the static analyzer nor its memo size cutoff: a board is only
+// reported unsolvable along with an UnsolvableProof.
+func (solver *Solver) SetExact(exact bool) {
+	solver.exact = exact
+}
+
+// ProveUnsolvable explores every state reachable from the solver's board.
+// It returns ErrSolvable if one of them satisfies the goal. The solver's
+// limits and context apply, with each state counting as a node.
+func (solver *Solver) ProveUnsolvable() (*UnsolvableProof, error) {
+	board := solver.board
+	if err := board.Validate(); err != nil {
+		return nil, err
+	}
+	board = board.Copy()
+	bestKey := *board.MemoKey()
+	bestBoard := board.Copy()
+	memo := NewMemo()
+	maxMemoSize := solver.limits.MaxMemoSize
+	solver.aborted = nil
+	var err error
+	var f func(int)
+	f = func(previousPiece int) {
+		if err != nil || !memo.Add(board.MemoKey(), 0) {
+			return
+		}
+		if solver.goal.IsSolved(board) {
+			err = ErrSolvable
+			return
+		}
+		solver.nodes++
+		if solver.abort() {
+			err = solver.aborted
+			return
+		}
+		if maxMemoSize > 0 && memo.Size() > maxMemoSize {
+			err = ErrMemoLimit
+			return
+		}
+		if board.MemoKey().Less(&bestKey, true) {
+			bestKey = *board.MemoKey()
+			bestBoard = board.Copy()
+		}
+		for _, move := range board.Moves(nil) {
+			if move.Piece == previousPiece {
+				continue
+			}
+			board.DoMove(move)
+			f(move.Piece)
+			board.UndoMove(move)
+		}
+	}
+	f(-1)
+	if err != nil {
+		return nil, err
+	}
+	bestBoard.SortPieces()
+	return &UnsolvableProof{memo.Size(), bestBoard}, nil
+}
+
+// unsolvable returns the result of a solve that found no solution within
+// depth moves. In exact mode it first proves the board unsolvable, and it
+// returns false if the board turns out to be solvable after all.
+func (solver *Solver) unsolvable(depth int) (Solution, bool) {
+	memo := solver.memo
+	result := Solution{
+		Depth:         depth,
+		MemoSize:      memo.Size(),
+		MemoHits:      memo.Hits(),
+		MemoMisses:    memo.Misses(),
+		MemoEvictions: memo.Evictions(),
+		Nodes:         solver.nodes,
+	}
+	if !solver.exact {
+		return result, true
+	}
+	proof, err := solver.ProveUnsolvable()
+	if err == ErrSolvable {
+		return Solution{}, false
+	}
+	result.Nodes = solver.nodes
+	result.Proof = proof
+	result.Aborted = err
+	return result, true
+}
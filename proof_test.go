@@ -0,0 +1,61 @@
+package rush
+
+import "testing"
+
+func TestProveUnsolvable(t *testing.T) {
+	// unsolvable, but not recognized by static analysis
+	board, err := NewBoardFromString("..BBBD..E..DAAE..D..EHHHCC.F...GGF..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if board.Impossible() {
+		t.Fatal("expected static analysis to miss this board")
+	}
+	proof, err := board.ProveUnsolvable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proof.States != board.ReachableStates() {
+		t.Fatalf("got %d states, want %d", proof.States, board.ReachableStates())
+	}
+	if proof.Canonical.String() != board.Canonicalize().String() {
+		t.Fatalf("got canonical board\n%s", proof.Canonical)
+	}
+	solver := NewSolver(board)
+	solver.SetExact(true)
+	if solution := solver.Solve(); solution.Solvable || solution.Proof == nil {
+		t.Fatalf("expected proof, got %s", solution)
+	}
+	if solution := board.Solve(); solution.Proof != nil {
+		t.Fatal("expected no proof outside exact mode")
+	}
+
+	// exact mode does not take the static analyzer's word for it
+	board, err = NewBoardFromString("FF.BC....BC.AA.BC....DDDHHH...EEEGGG")
+	if err != nil {
+		t.Fatal(err)
+	}
+	solver = NewSolver(board)
+	solver.SetExact(true)
+	if solution := solver.Solve(); solution.Proof == nil || solution.Proof.States != board.ReachableStates() {
+		t.Fatalf("expected proof, got %s", solution)
+	}
+
+	board, err = NewBoardFromString("BBBCDEFGGCDEF.AADEHHI....JI.KK.JLLMM")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := board.ProveUnsolvable(); err != ErrSolvable {
+		t.Fatalf("expected ErrSolvable, got %v", err)
+	}
+	solver = NewSolver(board)
+	solver.SetExact(true)
+	if solution := solver.Solve(); !solution.Solvable || solution.NumMoves != board.Solve().NumMoves {
+		t.Fatalf("unexpected %s", solution)
+	}
+	solver = NewSolver(board)
+	solver.SetLimits(Limits{MaxMemoSize: 100})
+	if _, err := solver.ProveUnsolvable(); err != ErrMemoLimit {
+		t.Fatalf("expected memo limit, got %v", err)
+	}
+}
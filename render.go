@@ -37,7 +37,9 @@ const (
 const labelFont = "/Library/Fonts/Arial.ttf"
 const footerFont = "/System/Library/Fonts/Menlo.ttc"
 
-func renderBoard(board *Board) image.Image {
+// renderBoard draws the board, with the deductions of explanation overlaid
+// if it is not nil.
+func renderBoard(board *Board, explanation *StaticExplanation) image.Image {
 	const S = cellSize
 	bw := board.Width
 	bh := board.Height
@@ -65,12 +67,28 @@ func renderBoard(board *Board) image.Image {
 		dc.SetHexColor(blockedColor)
 		dc.Fill()
 	}
-	ex := float64(bw) * S
-	ey := float64(board.Pieces[0].Row(bw))*S + S/2
+	// squares blocked by the explanation, labeled with the first step that
+	// blocked them once the pieces are drawn
+	steps := make(map[int]int)
+	if explanation != nil {
+		for _, d := range explanation.shown() {
+			for _, i := range d.Blocked {
+				if _, ok := steps[i]; !ok {
+					steps[i] = d.Step
+				}
+				x := float64(i % bw)
+				y := float64(i / bw)
+				dc.DrawRectangle(x*S, y*S, S, S)
+			}
+		}
+		dc.SetHexColor(blockedColor)
+		dc.Fill()
+	}
+	ex, ey, edx, edy := exitArrow(board)
 	es := float64(S) / 10
-	dc.LineTo(ex, ey+es)
-	dc.LineTo(ex, ey-es)
-	dc.LineTo(ex+es, ey)
+	dc.LineTo(ex-edy*es, ey+edx*es)
+	dc.LineTo(ex+edy*es, ey-edx*es)
+	dc.LineTo(ex+edx*es, ey+edy*es)
 	dc.SetHexColor(gridLineColor)
 	dc.Fill()
 	p := S / 8.0
@@ -134,22 +152,34 @@ func renderBoard(board *Board) image.Image {
 			tx := px + pw/2
 			ty := py + ph/2
 			dc.SetHexColor(labelColor)
-			dc.DrawStringAnchored(string('A'+i), tx, ty, 0.5, 0.5)
+			dc.DrawStringAnchored(board.Label(i), tx, ty, 0.5, 0.5)
 		}
 	}
 
+	for i, step := range steps {
+		x := float64(i % bw)
+		y := float64(i / bw)
+		dc.SetHexColor(labelColor)
+		dc.DrawStringAnchored(fmt.Sprint(step), x*S+S/6, y*S+S/6, 0.5, 0.5)
+	}
+	if explanation != nil && explanation.Square >= 0 {
+		i := explanation.Square
+		x := float64(i % bw)
+		y := float64(i / bw)
+		dc.DrawRectangle(x*S, y*S, S, S)
+		dc.SetLineWidth(S / 16.0)
+		dc.SetHexColor(primaryPieceColor)
+		dc.Stroke()
+	}
+
 	if showSolution {
 		x := float64(w) / 2
 		y := float64(h) + padding*0.75
 		footer := ""
 		solution := board.Solve()
 		if solution.Solvable {
-			moveStrings := make([]string, len(solution.Moves))
-			for i, move := range solution.Moves {
-				moveStrings[i] = move.String()
-			}
 			footer = fmt.Sprintf("%s (%d moves)",
-				strings.Join(moveStrings, " "), solution.NumMoves)
+				strings.Join(solution.MoveStrings(), " "), solution.NumMoves)
 		}
 		dc.LoadFontFace(footerFont, footerFontSize)
 		var tw float64
@@ -162,3 +192,23 @@ func renderBoard(board *Board) image.Image {
 
 	return dc.Image()
 }
+
+// exitArrow returns where the exit arrow starts on the edge of the grid, in
+// pixels, and the direction it points out of the grid. It is centered on the
+// exit's row or column.
+func exitArrow(board *Board) (x, y, dx, dy float64) {
+	const S = cellSize
+	w := float64(board.Width * S)
+	h := float64(board.Height * S)
+	lane := float64(board.exitLane()*S) + S/2
+	switch board.Exit {
+	case ExitLeft:
+		return 0, lane, -1, 0
+	case ExitTop:
+		return lane, 0, 0, -1
+	case ExitBottom:
+		return lane, h, 0, 1
+	default:
+		return w, lane, 1, 0
+	}
+}
@@ -0,0 +1,34 @@
+package rush
+
+import "testing"
+
+func TestExitArrow(t *testing.T) {
+	board, err := NewBoardFromString("BB.C..D..C..DAAC..D.EE..F.....F.GGG.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const S = cellSize
+	boards, _ := exitVariants(t, board)
+	tests := []struct {
+		exit         Exit
+		x, y, dx, dy float64
+		lane         int
+		laneX, laneY float64
+	}{
+		{ExitRight, 6 * S, 2*S + S/2, 1, 0, 4, 6 * S, 4*S + S/2},
+		{ExitLeft, 0, 2*S + S/2, -1, 0, 4, 0, 4*S + S/2},
+		{ExitBottom, 2*S + S/2, 6 * S, 0, 1, 4, 4*S + S/2, 6 * S},
+		{ExitTop, 2*S + S/2, 0, 0, -1, 4, 4*S + S/2, 0},
+	}
+	for _, test := range tests {
+		b := boards[test.exit]
+		x, y, dx, dy := exitArrow(b)
+		if x != test.x || y != test.y || dx != test.dx || dy != test.dy {
+			t.Fatalf("%s: got arrow at (%g, %g) pointing (%g, %g)", test.exit, x, y, dx, dy)
+		}
+		b.ExitLane = &test.lane
+		if x, y, _, _ = exitArrow(b); x != test.laneX || y != test.laneY {
+			t.Fatalf("%s: got arrow at (%g, %g) for lane %d", test.exit, x, y, test.lane)
+		}
+	}
+}
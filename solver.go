@@ -1,28 +1,109 @@
 package rush
 
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+)
+
+// Solution is the result of a solve. Labels holds the solved board's piece
+// labels, if any, so that moves can be printed the way the board was written.
+// Aborted is non-nil if the solve was stopped before it could finish, in
+// which case Depth is the depth reached and no solution is shorter. Cost is
+// the total cost of the moves under the CostModel that was minimized, which
+// for Solver is the number of moves. Proof is set on unsolvable Solutions
+// from a Solver in exact mode. MemoHits counts memo lookups, of which
+// MemoMisses found no entry, and MemoEvictions counts the states a
+// BoundedMemo dropped for lack of room.
 type Solution struct {
-	Solvable bool
-	Moves    []Move
-	NumMoves int
-	NumSteps int
-	Depth    int
-	MemoSize int
-	MemoHits uint64
+	Solvable      bool
+	Moves         []Move
+	Labels        []string
+	NumMoves      int
+	NumSteps      int
+	Cost          int
+	Depth         int
+	MemoSize      int
+	MemoHits      uint64
+	MemoMisses    uint64
+	MemoEvictions uint64
+	Nodes         uint64
+	Aborted       error
+	Proof         *UnsolvableProof
+}
+
+// MoveStrings returns the moves in "A+1" notation using the solution's
+// piece labels.
+func (solution Solution) MoveStrings() []string {
+	board := Board{Labels: solution.Labels}
+	moves := make([]string, len(solution.Moves))
+	for i, move := range solution.Moves {
+		moves[i] = board.MoveString(move)
+	}
+	return moves
+}
+
+func (solution Solution) String() string {
+	if solution.Aborted != nil {
+		return fmt.Sprintf("aborted at depth %d: %v", solution.Depth, solution.Aborted)
+	}
+	if solution.Proof != nil {
+		return fmt.Sprintf("unsolvable: none of %d reachable states is solved", solution.Proof.States)
+	}
+	if !solution.Solvable {
+		return "unsolvable"
+	}
+	moves := solution.MoveStrings()
+	return fmt.Sprintf("%d moves, %d steps: %s",
+		solution.NumMoves, solution.NumSteps, strings.Join(moves, " "))
+}
+
+// Limits bounds the work done by a Solver or Unsolver. Zero means no limit.
+// Counts accumulate over every solve made with the same Solver.
+type Limits struct {
+	MaxNodes    uint64 // search nodes visited
+	MaxMemoSize int    // distinct states remembered
 }
 
+var (
+	ErrNodeLimit = errors.New("node limit reached")
+	ErrMemoLimit = errors.New("memo size limit reached")
+)
+
+// boundedMemoNodes is how many nodes per slot of a bounded memo a solve may
+// search when no node limit is set. A bounded memo forgets states, so its
+// misses do not show when every reachable state has been seen, and an
+// unsolvable board would otherwise be searched forever.
+const boundedMemoNodes = 1 << 12
+
+// contextCheckInterval is how many nodes are searched between checks of the
+// context, which are relatively slow.
+const contextCheckInterval = 1024
+
 type Solver struct {
-	board  *Board
-	target int
-	memo   *Memo
-	sa     *StaticAnalyzer
-	path   []Move
-	moves  [][]Move
+	board     *Board
+	goal      Goal
+	heuristic Heuristic
+	memo      memoTable
+	sa        *StaticAnalyzer
+	path      []Move
+	moves     [][]Move
+	nodes     uint64
+	limits    Limits
+	nodeLimit uint64 // forced by a bounded memo for the current solve
+	ctx       context.Context
+	aborted   error
+	workers   int
+	exact     bool
 }
 
 func NewSolverWithStaticAnalyzer(board *Board, sa *StaticAnalyzer) *Solver {
 	solver := Solver{}
 	solver.board = board
-	solver.target = board.Target()
+	solver.goal = DefaultGoal(board)
 	solver.memo = NewMemo()
 	solver.sa = sa
 	return &solver
@@ -32,11 +113,131 @@ func NewSolver(board *Board) *Solver {
 	return NewSolverWithStaticAnalyzer(board, theStaticAnalyzer)
 }
 
+// NewSolverWithGoal returns a Solver that searches for a state satisfying
+// goal instead of the primary piece reaching its exit.
+func NewSolverWithGoal(board *Board, goal Goal) *Solver {
+	solver := NewSolver(board)
+	solver.goal = goal
+	return solver
+}
+
+// NewSolverWithBoundedMemo returns a Solver whose memo uses at most about
+// the given number of bytes. See BoundedMemo. Unless SetLimits sets a node
+// limit, a solve gives up with ErrNodeLimit after boundedMemoNodes nodes per
+// slot of the memo, since it may not otherwise find out that a board is
+// unsolvable.
+func NewSolverWithBoundedMemo(board *Board, bytes int, policy ReplacementPolicy) *Solver {
+	solver := NewSolver(board)
+	solver.memo = NewBoundedMemo(bytes, policy)
+	return solver
+}
+
+// NewSolverWithHeuristic returns a Solver that also prunes with the lower
+// bound given by h, making it an IDA* search.
+func NewSolverWithHeuristic(board *Board, h Heuristic) *Solver {
+	solver := NewSolver(board)
+	solver.heuristic = h
+	return solver
+}
+
+// Reset retargets the solver to board so that it can be used again without
+// allocating a new memo and move buffers. Its settings are kept, except that
+// a solver looking for the default goal of its old board looks for the
+// default goal of the new one.
+func (solver *Solver) Reset(board *Board) {
+	if solver.goal == nil || solver.hasDefaultGoal() {
+		solver.goal = DefaultGoal(board)
+	}
+	solver.board = board
+	solver.memo.Clear()
+	solver.nodes = 0
+	solver.aborted = nil
+}
+
+// retarget is like Reset but keeps the memo, so that solving many states of
+// one cluster in turn does not clear the table for each of them. The goal
+// is left alone.
+func (solver *Solver) retarget(board *Board) {
+	solver.board = board
+	solver.nodes = 0
+	solver.aborted = nil
+}
+
+// solverPool holds solvers for the Board convenience methods, so that
+// solving many boards in turn does not allocate a solver for each one.
+var solverPool = sync.Pool{
+	New: func() interface{} {
+		return &Solver{memo: NewMemo(), sa: theStaticAnalyzer}
+	},
+}
+
+// maxPooledMemoSize is the largest memo kept in solverPool. Clearing is as
+// slow as the table is large, so the memos of hard boards are dropped.
+const maxPooledMemoSize = 1 << 20
+
+func acquireSolver(board *Board) *Solver {
+	solver := solverPool.Get().(*Solver)
+	solver.Reset(board)
+	return solver
+}
+
+func releaseSolver(solver *Solver) {
+	solver.board = nil
+	solver.goal = nil
+	if solver.memo.Size() > maxPooledMemoSize {
+		solver.memo = NewMemo()
+	}
+	solverPool.Put(solver)
+}
+
+// minMoves returns the best lower bound on the number of moves still needed
+// to solve board.
+func (solver *Solver) minMoves(board *Board) int {
+	result := solver.goal.MinMoves(board)
+	if solver.heuristic != nil {
+		result = maxInt(result, solver.heuristic.MinMoves(board))
+	}
+	return result
+}
+
+// SetLimits bounds the work done by later solves.
+func (solver *Solver) SetLimits(limits Limits) {
+	solver.limits = limits
+}
+
+// abort reports whether the search must stop, recording the reason.
+func (solver *Solver) abort() bool {
+	if solver.aborted != nil {
+		return true
+	}
+	limits := solver.limits
+	if limits.MaxNodes > 0 && solver.nodes > limits.MaxNodes {
+		solver.aborted = ErrNodeLimit
+	} else if solver.nodeLimit > 0 && solver.nodes > solver.nodeLimit {
+		solver.aborted = ErrNodeLimit
+	} else if limits.MaxMemoSize > 0 && solver.memo.Size() > limits.MaxMemoSize {
+		solver.aborted = ErrMemoLimit
+	} else if solver.ctx != nil && solver.nodes%contextCheckInterval == 0 {
+		solver.aborted = solver.ctx.Err()
+	}
+	return solver.aborted != nil
+}
+
 func (solver *Solver) isSolved() bool {
-	return solver.board.Pieces[0].Position == solver.target
+	return solver.goal.IsSolved(solver.board)
+}
+
+// hasDefaultGoal reports whether the solver is looking for the primary piece
+// at its exit, which is the only goal the static analyzer understands.
+func (solver *Solver) hasDefaultGoal() bool {
+	return isDefaultGoal(solver.board, solver.goal)
 }
 
 func (solver *Solver) search(depth, maxDepth, previousPiece int) bool {
+	solver.nodes++
+	if solver.abort() {
+		return false
+	}
 	height := maxDepth - depth
 	if height == 0 {
 		return solver.isSolved()
@@ -47,17 +248,8 @@ func (solver *Solver) search(depth, maxDepth, previousPiece int) bool {
 		return false
 	}
 
-	// count occupied squares between primary piece and target
-	primary := board.Pieces[0]
-	i0 := primary.Position + primary.Size
-	i1 := solver.target + primary.Size - 1
-	minMoves := 0
-	for i := i0; i <= i1; i++ {
-		if board.occupied[i] {
-			minMoves++
-		}
-	}
-	if minMoves >= height {
+	// prune if the goal cannot be reached in the remaining moves
+	if solver.minMoves(board) > height {
 		return false
 	}
 
@@ -83,57 +275,151 @@ func (solver *Solver) solve(skipChecks bool) Solution {
 	board := solver.board
 	memo := solver.memo
 
+	solver.aborted = nil
+	solver.nodeLimit = 0
+	defer func() { solver.nodeLimit = 0 }()
+	if solver.ctx != nil {
+		if err := solver.ctx.Err(); err != nil {
+			return Solution{Aborted: err}
+		}
+	}
+
+	// an exit out of line with the primary piece can never be reached
+	if solver.hasDefaultGoal() && !board.inLane(0, board.Target()) {
+		return Solution{}
+	}
+
 	if !skipChecks {
 		if err := board.Validate(); err != nil {
 			return Solution{}
 		}
-		if solver.sa.Impossible(board) {
-			return Solution{}
+		if solver.sa != nil && solver.hasDefaultGoal() && solver.sa.Impossible(board) {
+			if !solver.exact {
+				return Solution{}
+			}
+			if result, ok := solver.unsolvable(0); ok {
+				return result
+			}
 		}
 	}
 
 	if solver.isSolved() {
-		return Solution{Solvable: true}
+		return Solution{Solvable: true, Labels: board.Labels}
 	}
 
-	previousMemoSize := 0
+	previousMisses := uint64(0)
 	noChange := 0
 	cutoff := board.Width - board.Pieces[0].Size
-	for i := 1; ; i++ {
-		solver.path = make([]Move, i)
-		solver.moves = make([][]Move, i)
-		if solver.search(0, i, -1) {
-			moves := solver.path
+	if board.Pieces[0].Orientation == Vertical {
+		cutoff = board.Height - board.Pieces[0].Size
+	}
+	if !solver.hasDefaultGoal() {
+		// custom goals may need longer for their lower bounds to stop
+		// pruning once every reachable state has been seen
+		cutoff = board.Width + board.Height
+	}
+	if solver.heuristic != nil {
+		// a heuristic may count up to two moves per cell of the lane
+		cutoff = 2 * (board.Width + board.Height)
+	}
+	// no solution is longer than the number of states, whatever the memo
+	// remembers
+	maxDepth := maxStates(board)
+	// no solution is shorter than the lower bound at the start
+	start := maxInt(solver.minMoves(board), 1)
+	if start >= unsolvableMoves {
+		result, ok := solver.unsolvable(0)
+		if ok {
+			return result
+		}
+		start = 1
+	}
+	solvable := false
+	for i := start; ; i++ {
+		// the move buffers are kept across iterations and solves
+		for len(solver.moves) < i {
+			solver.moves = append(solver.moves, nil)
+		}
+		if len(solver.path) < i {
+			solver.path = make([]Move, i)
+		}
+		var found bool
+		if solver.workers > 1 {
+			found = solver.parallelSearch(i)
+		} else {
+			found = solver.search(0, i, -1)
+		}
+		if found {
+			moves := make([]Move, i)
+			copy(moves, solver.path)
 			steps := 0
 			for _, move := range moves {
 				steps += move.AbsSteps()
 			}
 			result := Solution{
-				Solvable: true,
-				Moves:    moves,
-				NumMoves: len(moves),
-				NumSteps: steps,
-				Depth:    i,
-				MemoSize: memo.Size(),
-				MemoHits: memo.Hits(),
+				Solvable:      true,
+				Moves:         moves,
+				Labels:        board.Labels,
+				NumMoves:      len(moves),
+				NumSteps:      steps,
+				Cost:          len(moves),
+				Depth:         i,
+				MemoSize:      memo.Size(),
+				MemoHits:      memo.Hits(),
+				MemoMisses:    memo.Misses(),
+				MemoEvictions: memo.Evictions(),
+				Nodes:         solver.nodes,
 			}
 			return result
 		}
-		memoSize := memo.Size()
-		if memoSize == previousMemoSize {
+		if solver.aborted != nil {
+			return Solution{
+				Depth:         i,
+				MemoSize:      memo.Size(),
+				MemoHits:      memo.Hits(),
+				MemoMisses:    memo.Misses(),
+				MemoEvictions: memo.Evictions(),
+				Nodes:         solver.nodes,
+				Aborted:       solver.aborted,
+			}
+		}
+		if bounded, ok := memo.(*BoundedMemo); ok && solver.limits.MaxNodes == 0 && solver.nodeLimit == 0 {
+			solver.nodeLimit = solver.nodes + uint64(bounded.Capacity())*boundedMemoNodes
+		}
+		// stop when an iteration finds no new states
+		misses := memo.Misses()
+		if misses == previousMisses {
 			noChange++
 		} else {
 			noChange = 0
 		}
-		if !skipChecks && noChange > cutoff {
-			return Solution{
-				Depth:    i,
-				MemoSize: memo.Size(),
-				MemoHits: memo.Hits(),
+		if !skipChecks && !solvable && (noChange > cutoff || i >= maxDepth) {
+			result, ok := solver.unsolvable(i)
+			if ok {
+				return result
 			}
+			// the cutoff was wrong, so deepen until the solution is found
+			solvable = true
+		}
+		previousMisses = misses
+	}
+}
+
+// maxStates returns an upper bound on the number of states reachable from
+// board: the product of the number of offsets each piece has in its lane.
+func maxStates(board *Board) int {
+	result := 1
+	for _, piece := range board.Pieces {
+		n := board.Width - piece.Size + 1
+		if piece.Orientation == Vertical {
+			n = board.Height - piece.Size + 1
+		}
+		if result > math.MaxInt32/n {
+			return math.MaxInt32
 		}
-		previousMemoSize = memoSize
+		result *= n
 	}
+	return result
 }
 
 func (solver *Solver) Solve() Solution {
@@ -143,3 +429,11 @@ func (solver *Solver) Solve() Solution {
 func (solver *Solver) UnsafeSolve() Solution {
 	return solver.solve(true)
 }
+
+// SolveContext is like Solve but stops early, returning a Solution with
+// Aborted set, if ctx is done.
+func (solver *Solver) SolveContext(ctx context.Context) Solution {
+	solver.ctx = ctx
+	defer func() { solver.ctx = nil }()
+	return solver.solve(false)
+}
@@ -0,0 +1,208 @@
+package rush
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestSolveLimits(t *testing.T) {
+	board, err := NewBoardFromString("BBBCDEFGGCDEF.AADEHHI....JI.KK.JLLMM")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	solver := NewSolver(board)
+	solver.SetLimits(Limits{MaxNodes: 1000})
+	solution := solver.Solve()
+	if solution.Aborted != ErrNodeLimit || solution.Solvable || solution.Depth == 0 {
+		t.Fatalf("expected node limit, got %s", solution)
+	}
+
+	solver = NewSolver(board)
+	solver.SetLimits(Limits{MaxMemoSize: 100})
+	if solution := solver.Solve(); solution.Aborted != ErrMemoLimit {
+		t.Fatalf("expected memo limit, got %s", solution)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if solution := board.SolveContext(ctx); solution.Aborted != context.Canceled {
+		t.Fatalf("expected cancellation, got %s", solution)
+	}
+	if _, err := board.CanonicalizeContext(ctx); err != context.Canceled {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+
+	// stop the unsolver soon after its initial solve
+	unsolver := NewUnsolver(board)
+	unsolver.SetLimits(Limits{MaxNodes: board.Solve().Nodes + 1000})
+	hardest, solution := unsolver.Unsolve()
+	if solution.Aborted != ErrNodeLimit || hardest == nil || !solution.Solvable {
+		t.Fatalf("expected node limit with a partial result, got %s", solution)
+	}
+	if err := hardest.CheckSolution(solution.Moves); err != nil {
+		t.Fatal(err)
+	}
+
+	// without limits the solve completes
+	if solution := board.SolveContext(context.Background()); solution.Aborted != nil || !solution.Solvable {
+		t.Fatalf("unexpected %s", solution)
+	}
+}
+
+func TestSolverReset(t *testing.T) {
+	var boards []*Board
+	for _, desc := range []string{
+		"BBBCDEFGGCDEF.AADEHHI....JI.KK.JLLMM",
+		"..BBBD..E..DAAE..D..EHHHCC.F...GGF..",
+		"BB.C..D..C..DAAC..D.EE..F.....F.GGG.",
+	} {
+		board, err := NewBoardFromString(desc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		boards = append(boards, board)
+	}
+	// a board with longer memo keys
+	large := NewEmptyBoard(16, 16)
+	large.AddPiece(Piece{7 * 16, 2, Horizontal})
+	for x := 0; x < 16; x++ {
+		large.AddPiece(Piece{x, 2, Vertical})
+		large.AddPiece(Piece{10*16 + x, 2, Vertical})
+	}
+	boards = append(boards, large, boards[0])
+
+	solver := NewSolver(boards[0])
+	var previous Solution
+	for i, board := range boards {
+		if i > 0 {
+			solver.Reset(board)
+		}
+		moves := append([]Move(nil), previous.Moves...)
+		expected := NewSolver(board).Solve()
+		solution := solver.Solve()
+		if solution.String() != expected.String() || solution.Nodes != expected.Nodes {
+			t.Fatalf("got %s, want %s", solution, expected)
+		}
+		if len(moves) != len(previous.Moves) || (len(moves) > 0 && moves[0] != previous.Moves[0]) {
+			t.Fatal("solution moves were reused")
+		}
+		previous = solution
+	}
+
+	small := boards[2]
+	smaller := small.Copy()
+	smaller.RemovePiece(len(smaller.Pieces) - 1)
+	unsolver := NewUnsolver(small)
+	for i, board := range []*Board{small, smaller, small} {
+		if i > 0 {
+			unsolver.Reset(board)
+		}
+		b1, s1 := NewUnsolver(board).Unsolve()
+		b2, s2 := unsolver.Unsolve()
+		if b1.String() != b2.String() || s1.String() != s2.String() {
+			t.Fatalf("got %s, want %s", s2, s1)
+		}
+	}
+}
+
+func TestUnsolve(t *testing.T) {
+	for _, desc := range []string{
+		"BB.C..D..C..DAAC..D.EE..F.....F.GGG.",
+		"..BBBD..E..DAAE..D..EHHHCC.F...GGF..",
+	} {
+		board, err := NewBoardFromString(desc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		maxMoves := 0
+		solvable := false
+		for b := range board.StateIterator() {
+			solution := b.Solve()
+			solvable = solvable || solution.Solvable
+			maxMoves = maxInt(maxMoves, solution.NumMoves)
+		}
+		hardest, solution := board.Unsolve()
+		if solution.Solvable != solvable || solution.NumMoves != maxMoves {
+			t.Fatalf("got %s, want %d moves", solution, maxMoves)
+		}
+		if solvable {
+			if err := hardest.CheckSolution(solution.Moves); err != nil {
+				t.Fatal(err)
+			}
+		} else if hardest.String() != board.String() {
+			t.Fatal("expected the board itself when unsolvable")
+		}
+
+		// limits solve the board first but find the same state
+		unsolver := NewUnsolver(board)
+		unsolver.SetLimits(Limits{MaxNodes: 1 << 40})
+		limited, limitedSolution := unsolver.Unsolve()
+		if limited.String() != hardest.String() || limitedSolution.String() != solution.String() {
+			t.Fatalf("got %s, want %s", limitedSolution, solution)
+		}
+	}
+}
+
+// solverUnsolve is how Unsolve used to work: it solves every state of the
+// cluster with one solver, keeping the one whose solution has the most
+// moves, then the most steps, then comes first by MemoKey.Less.
+func solverUnsolve(board *Board) (*Board, Solution) {
+	board = board.Copy()
+	solver := NewSolver(board)
+	bestBoard := board.Copy()
+	best := solver.Solve()
+	if !best.Solvable {
+		return bestBoard, best
+	}
+	memo := NewMemo()
+	var search func(previousPiece int)
+	search = func(previousPiece int) {
+		if !memo.Add(board.MemoKey(), 0) {
+			return
+		}
+		solution := solver.UnsafeSolve()
+		dMoves := solution.NumMoves - best.NumMoves
+		dSteps := solution.NumSteps - best.NumSteps
+		if dMoves > 0 || dMoves == 0 && (dSteps > 0 ||
+			dSteps == 0 && board.MemoKey().Less(bestBoard.MemoKey(), true)) {
+			best = solution
+			bestBoard = board.Copy()
+		}
+		for _, move := range board.Moves(nil) {
+			if move.Piece == previousPiece {
+				continue
+			}
+			board.DoMove(move)
+			search(move.Piece)
+			board.UndoMove(move)
+		}
+	}
+	search(-1)
+	return bestBoard, best
+}
+
+func TestUnsolveTieBreak(t *testing.T) {
+	for _, desc := range []string{
+		"BB.C..D..C..DAAC..D.EE..F.....F.GGG.",
+		"..B.CC..B...AAB...DDD..E.....E.....E",
+		"..BB..GGC...AACHD.F.CHD.F...D..EEE..",
+	} {
+		board, err := NewBoardFromString(desc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, wantSolution := solverUnsolve(board)
+		got, solution := board.Unsolve()
+		if got.String() != want.String() {
+			t.Fatalf("got board\n%s\nused to get\n%s", got, want)
+		}
+		if solution.Solvable != wantSolution.Solvable ||
+			!reflect.DeepEqual(solution.Moves, wantSolution.Moves) ||
+			solution.NumMoves != wantSolution.NumMoves ||
+			solution.NumSteps != wantSolution.NumSteps {
+			t.Fatalf("got %s, used to get %s", solution, wantSolution)
+		}
+	}
+}
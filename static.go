@@ -1,5 +1,7 @@
 package rush
 
+import "sync"
+
 /*
 
 Static analysis code is below. Its purpose is to detect if a Board will be
@@ -87,8 +89,16 @@ result = [4]    # blocked squares found by the algorithm
 
 var theStaticAnalyzer = NewStaticAnalyzer()
 
-type StaticAnalyzer struct {
-	// these buffers are allocated once so multiple static analyses can be
+// StaticAnalyzer is safe for concurrent use. Each analysis borrows its
+// scratch buffers from a pool shared by all analyzers.
+type StaticAnalyzer struct{}
+
+func NewStaticAnalyzer() *StaticAnalyzer {
+	return &StaticAnalyzer{}
+}
+
+type staticScratch struct {
+	// these buffers are reused so multiple static analyses can be
 	// performed faster (less GC)
 	horz       []bool
 	vert       []bool
@@ -100,60 +110,83 @@ type StaticAnalyzer struct {
 	counts     []int
 	result     []int
 	placements [][]int
+
+	// set by Explain to record each deduction
+	explain    bool
+	round      int
+	deductions []StaticDeduction
 }
 
-func NewStaticAnalyzer() *StaticAnalyzer {
-	maxPiecesPerRow := MaxBoardSize / MinPieceSize
-	maxPlacementsPerRow := MaxBoardSize - MinPieceSize + 1
-	sa := &StaticAnalyzer{}
-	sa.horz = make([]bool, MaxBoardSize*MaxBoardSize)
-	sa.vert = make([]bool, MaxBoardSize*MaxBoardSize)
+var staticScratchPool = sync.Pool{
+	New: func() interface{} {
+		return &staticScratch{}
+	},
+}
+
+// reserve grows the buffers as needed to analyze a w x h board.
+func (sa *staticScratch) reserve(w, h int) {
+	if n := w * h; len(sa.horz) < n {
+		sa.horz = make([]bool, n)
+		sa.vert = make([]bool, n)
+	}
+	size := maxInt(w, h)
+	if len(sa.blocked) >= size {
+		return
+	}
+	maxPiecesPerRow := size / MinPieceSize
+	maxPlacementsPerRow := size - MinPieceSize + 1
 	sa.positions = make([]int, maxPiecesPerRow)
 	sa.sizes = make([]int, maxPiecesPerRow)
-	sa.blocked = make([]int, MaxBoardSize)
+	sa.blocked = make([]int, size)
 	sa.lens = make([]int, maxPiecesPerRow)
 	sa.idx = make([]int, maxPiecesPerRow)
-	sa.counts = make([]int, MaxBoardSize)
-	sa.result = make([]int, MaxBoardSize)
+	sa.counts = make([]int, size)
+	sa.result = make([]int, size)
 	sa.placements = make([][]int, maxPiecesPerRow)
 	for i := range sa.placements {
 		sa.placements[i] = make([]int, maxPlacementsPerRow)
 	}
-	return sa
 }
 
 func (sa *StaticAnalyzer) Impossible(board *Board) bool {
 	// run analysis
-	sa.analyze(board)
+	scratch := staticScratchPool.Get().(*staticScratch)
+	defer staticScratchPool.Put(scratch)
+	scratch.analyze(board)
+	// an exit out of line with the primary piece can never be reached
+	if !board.inLane(0, board.Target()) {
+		return true
+	}
 	// see if any squares between the primary piece and its exit are blocked
-	w := board.Width
-	piece := board.Pieces[0]
-	i0 := piece.Position + piece.Size
-	i1 := (piece.Row(w) + 1) * w
-	for i := i0; i < i1; i++ {
-		if sa.horz[i] || sa.vert[i] {
+	idx, stride, n := board.piecePath(0, board.Target())
+	for i := 0; i < n; i++ {
+		if scratch.horz[idx] || scratch.vert[idx] {
 			return true
 		}
+		idx += stride
 	}
 	return false
 }
 
 func (sa *StaticAnalyzer) BlockedSquares(board *Board) []int {
 	// run analysis
-	sa.analyze(board)
+	scratch := staticScratchPool.Get().(*staticScratch)
+	defer staticScratchPool.Put(scratch)
+	scratch.analyze(board)
 	// compile a list of all blocked squares
 	n := board.Width * board.Height
 	var result []int
 	for i := 0; i < n; i++ {
-		if sa.horz[i] || sa.vert[i] {
+		if scratch.horz[i] || scratch.vert[i] {
 			result = append(result, i)
 		}
 	}
 	return result
 }
 
-func (sa *StaticAnalyzer) analyze(board *Board) {
-	// zero out buffers
+func (sa *staticScratch) analyze(board *Board) {
+	// size and zero out buffers
+	sa.reserve(board.Width, board.Height)
 	for i := range sa.horz {
 		sa.horz[i] = false
 		sa.vert[i] = false
@@ -164,11 +197,13 @@ func (sa *StaticAnalyzer) analyze(board *Board) {
 		sa.vert[i] = true
 	}
 	// run the step function until no more changes are made
+	sa.round = 0
 	for sa.step(board) {
 	}
 }
 
-func (sa *StaticAnalyzer) step(board *Board) bool {
+func (sa *staticScratch) step(board *Board) bool {
+	sa.round++
 	changed := false
 	w := board.Width
 	h := board.Height
@@ -197,13 +232,20 @@ func (sa *StaticAnalyzer) step(board *Board) bool {
 		}
 		// update blocked squares on this row
 		result := sa.blockedSquares(w, positions, sizes, blocked)
+		var cells []int
 		for _, i := range result {
 			i = i + i0
 			if !sa.vert[i] {
 				sa.vert[i] = true
 				changed = true
+				if sa.explain {
+					cells = append(cells, i)
+				}
 			}
 		}
+		if len(cells) > 0 {
+			sa.record(board, Horizontal, y, blocked, cells)
+		}
 	}
 	// iterate over cols
 	for x := 0; x < w; x++ {
@@ -229,19 +271,26 @@ func (sa *StaticAnalyzer) step(board *Board) bool {
 		}
 		// update blocked squares on this col
 		result := sa.blockedSquares(h, positions, sizes, blocked)
+		var cells []int
 		for _, i := range result {
 			i = i*w + x
 			if !sa.horz[i] {
 				sa.horz[i] = true
 				changed = true
+				if sa.explain {
+					cells = append(cells, i)
+				}
 			}
 		}
+		if len(cells) > 0 {
+			sa.record(board, Vertical, x, blocked, cells)
+		}
 	}
 	// return true if any changes were made
 	return changed
 }
 
-func (sa *StaticAnalyzer) blockedSquares(w int, positions, sizes, blocked []int) []int {
+func (sa *staticScratch) blockedSquares(w int, positions, sizes, blocked []int) []int {
 	n := len(positions)
 	// insertion sort the positions & sizes together
 	for i := 1; i < n; i++ {
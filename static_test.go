@@ -2,12 +2,15 @@ package rush
 
 import (
 	"reflect"
+	"sync"
 	"testing"
 )
 
 func TestBlockedSquares(t *testing.T) {
 	test := func(w int, positions, sizes, blocked, expected []int) {
-		result := theStaticAnalyzer.blockedSquares(w, positions, sizes, blocked)
+		var scratch staticScratch
+		scratch.reserve(w, 1)
+		result := scratch.blockedSquares(w, positions, sizes, blocked)
 		if !reflect.DeepEqual(result, expected) {
 			t.Fail()
 		}
@@ -31,3 +34,48 @@ func TestBlockedSquares(t *testing.T) {
 	// .xAAA.BBx.. => ...xx.x....
 	test(11, []int{2, 6}, []int{3, 2}, []int{1, 8}, []int{3, 4, 6})
 }
+
+func TestConcurrentStaticAnalysis(t *testing.T) {
+	var boards []*Board
+	for _, desc := range []string{
+		"BB.C..D..C..DAAC..D.EE..F.....F.GGG.",
+		"..BBBD..E..DAAE..D..EHHHCC.F...GGF..",
+		"FF.BC....BC.AA.BC....DDDHHH...EEEGGG",
+		"BBBCDEFGGCDEF.AADEHHI....JI.KK.JLLMM",
+	} {
+		board, err := NewBoardFromString(desc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		boards = append(boards, board)
+	}
+	type result struct {
+		impossible bool
+		blocked    []int
+		solution   string
+	}
+	expected := make([]result, len(boards))
+	for i, board := range boards {
+		expected[i] = result{board.Impossible(), board.BlockedSquares(), board.Solve().String()}
+	}
+
+	// run with -race to check that solves share nothing unsynchronized
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for k := range boards {
+				i := (g + k) % len(boards)
+				// solving moves the board's pieces, so each goroutine
+				// needs its own copy
+				board := boards[i].Copy()
+				got := result{board.Impossible(), board.BlockedSquares(), board.Solve().String()}
+				if !reflect.DeepEqual(got, expected[i]) {
+					t.Errorf("got %v, want %v", got, expected[i])
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,189 @@
+package rush
+
+/*
+
+Memo grows to hold every state the solver visits, which for long unsolves
+and batches of solves can be more memory than a shared machine has to spare.
+BoundedMemo is a transposition table of fixed size. Its slots are grouped
+into buckets of four, and a state may only live in the bucket its key hashes
+to. When a bucket is full one of its entries must go, chosen by the table's
+ReplacementPolicy.
+
+Losing an entry never makes the solver wrong, only slower, because the memo
+is only used to prune states that were already searched. The one exception
+is the solver's check for unsolvable boards, which waits for the search to
+stop finding new states: if the reachable states do not all fit in the table
+that never happens, so unsolvable boards should be solved with Limits.
+
+*/
+
+type ReplacementPolicy int
+
+const (
+	// DepthPreferred evicts the entry with the least remaining depth, keeping
+	// the deeper ones, which each save the most work. A new entry shallower
+	// than every entry of its bucket is dropped instead.
+	DepthPreferred ReplacementPolicy = iota
+	// AlwaysReplace always stores the new entry, evicting an older one.
+	AlwaysReplace
+)
+
+const boundedMemoBucketSize = 4
+
+type BoundedMemo struct {
+	bytes     int
+	policy    ReplacementPolicy
+	width     int // words per key, set by the first key
+	keys      []uint64
+	depths    []int32
+	size      int
+	hits      uint64
+	misses    uint64
+	evictions uint64
+	buf       []uint64
+}
+
+// NewBoundedMemo returns a table that uses at most about the given number
+// of bytes, and never fewer than one bucket.
+func NewBoundedMemo(bytes int, policy ReplacementPolicy) *BoundedMemo {
+	return &BoundedMemo{bytes: bytes, policy: policy}
+}
+
+func (memo *BoundedMemo) Size() int {
+	return memo.size
+}
+
+func (memo *BoundedMemo) Hits() uint64 {
+	return memo.hits
+}
+
+func (memo *BoundedMemo) Misses() uint64 {
+	return memo.misses
+}
+
+// Evictions returns how many entries were replaced to make room for others.
+func (memo *BoundedMemo) Evictions() uint64 {
+	return memo.evictions
+}
+
+// Capacity returns the number of slots in the table, or zero before the
+// first key sets the size of its entries.
+func (memo *BoundedMemo) Capacity() int {
+	return len(memo.depths)
+}
+
+// words unpacks key into the memo's scratch buffer, allocating the largest
+// power of two slots that fit in the table's bytes on first use. An empty
+// table may change to keys of a different size.
+func (memo *BoundedMemo) words(key *MemoKey) []uint64 {
+	memo.buf = key.appendWords(memo.buf[:0])
+	if len(memo.buf) != memo.width {
+		if memo.size != 0 {
+			panic("rush: memo keys have different sizes")
+		}
+		memo.width = len(memo.buf)
+		n := memo.bytes / (memo.width*8 + 4)
+		slots := boundedMemoBucketSize
+		for slots*2 <= n {
+			slots *= 2
+		}
+		memo.keys = make([]uint64, slots*memo.width)
+		memo.depths = make([]int32, slots)
+	}
+	return memo.buf
+}
+
+// Clear removes every entry and resets the counters, keeping the table's
+// storage for reuse.
+func (memo *BoundedMemo) Clear() {
+	for i := range memo.depths {
+		memo.depths[i] = 0
+	}
+	memo.size = 0
+	memo.hits = 0
+	memo.misses = 0
+	memo.evictions = 0
+}
+
+// find returns the first slot of the bucket for key, the key's hash and the
+// slot holding key, or -1 if it is not in the table.
+func (memo *BoundedMemo) find(key []uint64) (int, uint64, int) {
+	h := hashWords(key)
+	b := int(h) & (len(memo.depths) - 1) &^ (boundedMemoBucketSize - 1)
+	for i := b; i < b+boundedMemoBucketSize; i++ {
+		if memo.depths[i] != 0 && memo.equal(i, key) {
+			return b, h, i
+		}
+	}
+	return b, h, -1
+}
+
+func (memo *BoundedMemo) equal(i int, key []uint64) bool {
+	keys := memo.keys[i*memo.width:]
+	for j, x := range key {
+		if keys[j] != x {
+			return false
+		}
+	}
+	return true
+}
+
+func (memo *BoundedMemo) Add(key *MemoKey, depth int) bool {
+	memo.hits++
+	words := memo.words(key)
+	b, h, i := memo.find(words)
+	if i >= 0 {
+		if int(memo.depths[i])-1 >= depth {
+			return false
+		}
+		memo.depths[i] = int32(depth) + 1
+		return true
+	}
+	memo.misses++
+	memo.store(b, h, words, depth)
+	return true
+}
+
+func (memo *BoundedMemo) Set(key *MemoKey, depth int) {
+	words := memo.words(key)
+	b, h, i := memo.find(words)
+	if i >= 0 {
+		memo.depths[i] = int32(depth) + 1
+		return
+	}
+	memo.store(b, h, words, depth)
+}
+
+// store puts key in the bucket starting at slot b, making room according
+// to the replacement policy if the bucket is full.
+func (memo *BoundedMemo) store(b int, h uint64, key []uint64, depth int) {
+	i := -1
+	for j := b; j < b+boundedMemoBucketSize; j++ {
+		if memo.depths[j] == 0 {
+			i = j
+			break
+		}
+	}
+	if i < 0 {
+		memo.evictions++
+		if memo.policy == AlwaysReplace {
+			// the top bits of the hash were not used to pick the bucket
+			i = b + int(h>>62)
+		} else {
+			i = b
+			for j := b + 1; j < b+boundedMemoBucketSize; j++ {
+				if memo.depths[j] < memo.depths[i] {
+					i = j
+				}
+			}
+			if int32(depth)+1 < memo.depths[i] {
+				// the new entry is the shallowest, so it is the one to go
+				return
+			}
+		}
+	} else {
+		memo.size++
+	}
+	copy(memo.keys[i*memo.width:], key)
+	memo.depths[i] = int32(depth) + 1
+}
@@ -1,11 +1,15 @@
 package rush
 
+import "context"
+
 type Unsolver struct {
 	board        *Board
 	solver       *Solver
-	memo         *Memo
 	bestBoard    *Board
 	bestSolution Solution
+	limits       Limits
+	objective    Objective
+	aborted      error
 }
 
 func NewUnsolverWithStaticAnalyzer(board *Board, sa *StaticAnalyzer) *Unsolver {
@@ -13,7 +17,6 @@ func NewUnsolverWithStaticAnalyzer(board *Board, sa *StaticAnalyzer) *Unsolver {
 	u := Unsolver{}
 	u.board = board
 	u.solver = NewSolverWithStaticAnalyzer(board, sa)
-	u.memo = NewMemo()
 	return &u
 }
 
@@ -21,52 +24,159 @@ func NewUnsolver(board *Board) *Unsolver {
 	return NewUnsolverWithStaticAnalyzer(board, theStaticAnalyzer)
 }
 
-func (u *Unsolver) search(previousPiece int) {
-	board := u.board
+// NewUnsolverWithGoal returns an Unsolver that finds the hardest reachable
+// state with respect to goal.
+func NewUnsolverWithGoal(board *Board, goal Goal) *Unsolver {
+	u := NewUnsolver(board)
+	u.solver.goal = goal
+	return u
+}
 
-	if !u.memo.Add(board.MemoKey(), 0) {
-		return
+// NewUnsolverWithBoundedMemo returns an Unsolver whose solver memo uses at
+// most about the given number of bytes. The cluster the unsolver searches is
+// not bounded.
+func NewUnsolverWithBoundedMemo(board *Board, bytes int, policy ReplacementPolicy) *Unsolver {
+	u := NewUnsolver(board)
+	u.solver.memo = NewBoundedMemo(bytes, policy)
+	return u
+}
+
+// Reset retargets the unsolver to board, reusing its solver.
+// Settings are kept as for Solver.Reset.
+func (u *Unsolver) Reset(board *Board) {
+	u.board = board.Copy()
+	u.solver.Reset(u.board)
+	u.bestBoard = nil
+	u.bestSolution = Solution{}
+	u.aborted = nil
+}
+
+// SetLimits bounds the work done by later unsolves. The node limit applies
+// to the total of the first solve and the states expanded by the cluster
+// search, and the memo size limit applies both to the solver and to the
+// states of the cluster.
+func (u *Unsolver) SetLimits(limits Limits) {
+	u.limits = limits
+	u.solver.SetLimits(limits)
+}
+
+// cluster searches the board's cluster, counting each state expanded as a
+// node of the solver.
+func (u *Unsolver) cluster() (*Cluster, error) {
+	u.solver.aborted = nil
+	return buildCluster(u.board, u.solver.goal, false, u.abortCluster)
+}
+
+func (u *Unsolver) abortCluster(states int) error {
+	solver := u.solver
+	solver.nodes++
+	if solver.abort() {
+		return solver.aborted
 	}
+	if n := u.limits.MaxMemoSize; n > 0 && states > n {
+		return ErrMemoLimit
+	}
+	return nil
+}
 
-	solution := u.solver.UnsafeSolve()
-
-	better := false
-	dNumMoves := solution.NumMoves - u.bestSolution.NumMoves
-	dNumSteps := solution.NumSteps - u.bestSolution.NumSteps
-	if dNumMoves >= 0 {
-		if dNumMoves > 0 {
-			better = true
-		} else if dNumMoves == 0 && dNumSteps > 0 {
-			better = true
-		} else if dNumMoves == 0 && dNumSteps == 0 && board.MemoKey().Less(u.bestBoard.MemoKey(), true) {
-			better = true
+// search finds the best state of the board's cluster, whose solution comes
+// from the cluster's distances, or the board itself if no state is solved
+// or meets the objective.
+func (u *Unsolver) search() {
+	cluster, err := u.cluster()
+	if err != nil {
+		u.aborted = err
+		return
+	}
+	hardest := u.board
+	solution := cluster.Solution(hardest)
+	if u.objective != nil {
+		results, err := u.rank(cluster, 1)
+		if err != nil {
+			u.aborted = err
+			return
+		}
+		if len(results) > 0 {
+			hardest = results[0].Board
+			solution = cluster.Solution(hardest)
+		}
+	} else if cluster.Solvable {
+		hardest, solution = u.hardest(cluster)
+		if solution.Aborted != nil {
+			u.aborted = solution.Aborted
+			return
 		}
 	}
+	u.bestBoard = hardest.Copy()
+	u.bestSolution = solution
+	u.bestSolution.Nodes = u.solver.nodes
+}
 
-	if better {
-		u.bestSolution = solution
-		u.bestBoard = board.Copy()
+// hardest picks the hardest state of the cluster as Unsolve always has: the
+// most moves, then the most steps in the solver's solution, then first by
+// MemoKey.Less. Only the states furthest from being solved are solved, each
+// with a cleared memo so that its solution does not depend on the others.
+func (u *Unsolver) hardest(cluster *Cluster) (*Board, Solution) {
+	solver := u.solver
+	defer func() { solver.board = u.board }()
+	var best *Board
+	var bestSolution Solution
+	for _, board := range cluster.hardest {
+		solver.board = board
+		solver.memo.Clear()
+		solution := solver.solve(true)
+		if solution.Aborted != nil {
+			return nil, solution
+		}
+		if best == nil || solution.NumSteps > bestSolution.NumSteps ||
+			solution.NumSteps == bestSolution.NumSteps && board.MemoKey().Less(best.MemoKey(), true) {
+			best = board
+			bestSolution = solution
+		}
 	}
+	return best, bestSolution
+}
 
-	for _, move := range board.Moves(nil) {
-		if move.Piece == previousPiece {
-			continue
-		}
-		board.DoMove(move)
-		u.search(move.Piece)
-		board.UndoMove(move)
+// possible reports whether the board passes the checks that Solve makes
+// before it searches.
+func (u *Unsolver) possible() bool {
+	solver := u.solver
+	if err := u.board.Validate(); err != nil {
+		return false
 	}
+	return solver.sa == nil || !solver.hasDefaultGoal() || !solver.sa.Impossible(u.board)
 }
 
 func (u *Unsolver) unsolve(skipChecks bool) (*Board, Solution) {
 	u.bestBoard = u.board.Copy()
+	u.aborted = nil
+	if u.limits == (Limits{}) && u.solver.ctx == nil {
+		// nothing can stop the search, so the cluster alone decides
+		u.bestSolution = Solution{}
+		if skipChecks || u.possible() {
+			u.search()
+		}
+		return u.bestBoard, u.bestSolution
+	}
+	// solve the board first, so that there is a result if the search of
+	// its cluster is stopped
 	u.bestSolution = u.solver.solve(skipChecks)
-	if u.bestSolution.Solvable {
-		u.search(-1)
+	u.aborted = u.bestSolution.Aborted
+	if u.aborted == nil && (skipChecks || u.possible()) {
+		// the cluster and objective decide, as they do without limits,
+		// even if the solve gave up on the board
+		u.search()
 	}
+	u.bestSolution.Aborted = u.aborted
 	return u.bestBoard, u.bestSolution
 }
 
+// Unsolve returns the hardest state reachable from the board, which takes
+// the most moves to solve, then the most steps, then comes first by
+// MemoKey.Less, along with its solution, or the best state by the objective
+// set with SetObjective along with its solution of the fewest steps. Without
+// limits or a context the board's cluster is searched directly, without
+// solving the board first.
 func (u *Unsolver) Unsolve() (*Board, Solution) {
 	return u.unsolve(false)
 }
@@ -74,3 +184,12 @@ func (u *Unsolver) Unsolve() (*Board, Solution) {
 func (u *Unsolver) UnsafeUnsolve() (*Board, Solution) {
 	return u.unsolve(true)
 }
+
+// UnsolveContext is like Unsolve but stops early if ctx is done. The
+// starting board is then returned along with its solution, if it was found,
+// with Aborted set.
+func (u *Unsolver) UnsolveContext(ctx context.Context) (*Board, Solution) {
+	u.solver.ctx = ctx
+	defer func() { u.solver.ctx = nil }()
+	return u.unsolve(false)
+}
@@ -0,0 +1,60 @@
+package rush
+
+import "fmt"
+
+// Verification is the result of checking a claimed Solution against a
+// Board. Err explains the first problem found, if any.
+type Verification struct {
+	Legal   bool     // every move could be made in turn
+	Solved  bool     // the moves leave the primary piece at its target
+	Optimal bool     // no solution uses fewer moves
+	Best    Solution // the solver's solution, used to judge optimality
+	Err     error
+}
+
+// Verify checks a Solution that did not necessarily come from this package,
+// such as a player submission or a stored database row. It replays the
+// moves on a copy of board, then solves the board to see whether the moves
+// are optimal.
+func (solution Solution) Verify(board *Board) Verification {
+	var v Verification
+	if err := board.Validate(); err != nil {
+		v.Err = err
+		return v
+	}
+
+	// the claimed counts must agree with the moves themselves
+	steps := 0
+	for _, move := range solution.Moves {
+		steps += move.AbsSteps()
+	}
+	if solution.NumMoves != len(solution.Moves) {
+		v.Err = fmt.Errorf("solution claims %d moves but has %d", solution.NumMoves, len(solution.Moves))
+	} else if solution.NumSteps != steps {
+		v.Err = fmt.Errorf("solution claims %d steps but has %d", solution.NumSteps, steps)
+	}
+
+	// replay the moves
+	b := board.Copy()
+	if err := b.ApplyMoves(solution.Moves); err != nil {
+		v.Err = err
+		return v
+	}
+	v.Legal = true
+	v.Solved = b.Solved()
+
+	// compare with the solver
+	v.Best = board.Solve()
+	v.Optimal = v.Solved && v.Best.Solvable && len(solution.Moves) == v.Best.NumMoves
+
+	if v.Err != nil {
+		return v
+	}
+	switch {
+	case !solution.Solvable && v.Best.Solvable:
+		v.Err = fmt.Errorf("solution claims unsolvable but board is solvable in %d moves", v.Best.NumMoves)
+	case solution.Solvable && !v.Solved:
+		v.Err = fmt.Errorf("moves do not solve the board")
+	}
+	return v
+}
@@ -0,0 +1,79 @@
+package rush
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	board, err := NewBoardFromString("..B.CC..B...AAB...DDD..E.....E.....E")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsolvable, err := NewBoardFromString("..BBBD..E..DAAE..D..EHHHCC.F...GGF..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	best := board.Solve()
+	if !best.Solvable {
+		t.Fatal("expected a solvable board")
+	}
+	claim := func(s string) Solution {
+		moves, err := ParseMoves(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		solution := Solution{Solvable: true, Moves: moves, NumMoves: len(moves)}
+		for _, move := range moves {
+			solution.NumSteps += move.AbsSteps()
+		}
+		return solution
+	}
+	bestMoves := best.MoveStrings()
+	optimal := claim(strings.Join(bestMoves, " "))
+	suboptimal := claim("C-1 C+1 " + strings.Join(bestMoves, " "))
+	miscounted := claim(strings.Join(bestMoves, " "))
+	miscounted.NumSteps++
+
+	tests := []struct {
+		name     string
+		board    *Board
+		solution Solution
+		legal    bool
+		solved   bool
+		optimal  bool
+		index    int // index of the illegal move, or -1
+		err      bool
+	}{
+		{"illegal", board, claim("C-1 A+2 D+3"), false, false, false, 1, true},
+		{"off the grid", board, claim("E-4"), false, false, false, 0, true},
+		{"unsolved", board, claim("C-1 D+2"), true, false, false, -1, true},
+		{"suboptimal", board, suboptimal, true, true, false, -1, false},
+		{"optimal", board, optimal, true, true, true, -1, false},
+		{"miscounted", board, miscounted, true, true, true, -1, true},
+		{"claimed unsolvable", board, Solution{}, true, false, false, -1, true},
+		{"unsolvable", unsolvable, Solution{}, true, false, false, -1, false},
+		{"unsolvable with moves", unsolvable, claim("B-2"), true, false, false, -1, true},
+	}
+	for _, test := range tests {
+		v := test.solution.Verify(test.board)
+		if v.Legal != test.legal || v.Solved != test.solved || v.Optimal != test.optimal {
+			t.Fatalf("%s: got legal %v, solved %v, optimal %v", test.name, v.Legal, v.Solved, v.Optimal)
+		}
+		if (v.Err != nil) != test.err {
+			t.Fatalf("%s: unexpected error %v", test.name, v.Err)
+		}
+		if test.index >= 0 {
+			e, ok := v.Err.(*MoveError)
+			if !ok || e.Index != test.index {
+				t.Fatalf("%s: expected error at move %d, got %v", test.name, test.index+1, v.Err)
+			}
+		}
+		if v.Legal && v.Best.Solvable != (test.board == board) {
+			t.Fatalf("%s: got best %s", test.name, v.Best)
+		}
+		if v.Legal && v.Best.Solvable && v.Best.NumMoves != best.NumMoves {
+			t.Fatalf("%s: got best %s, want %s", test.name, v.Best, best)
+		}
+	}
+}